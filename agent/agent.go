@@ -8,6 +8,7 @@ package agent
 
 import (
 	"bytes"
+	"math/rand"
 	"runtime"
 	"strings"
 	"syscall"
@@ -34,6 +35,8 @@ const (
 	Linux
 	// Macintosh/OS X operating system.
 	Macintosh
+	// iOS mobile operating system.
+	IOS
 )
 
 // TemplateData structure for template data.
@@ -54,9 +57,10 @@ type OSAttributes struct {
 
 // DefaultOSAttributes stores default OS attributes.
 var DefaultOSAttributes = map[int]OSAttributes{
-	Windows:   {"Windows NT", "6.3", []string{"x64"}},
+	Windows:   {"Windows NT", "10.0", []string{"Win64", "x64"}},
 	Linux:     {"Linux", "3.16.1", []string{"x64"}},
-	Macintosh: {"Intel Mac OS X", "10_6_8", []string{}},
+	Macintosh: {"Intel Mac OS X", "10_15_7", []string{}},
+	IOS:       {"CPU iPhone OS", "18_5", []string{}},
 }
 
 // Formats is a collection of UA format strings.
@@ -75,9 +79,11 @@ type UATable map[string]UAData
 // Database is the "database" of user agents.
 var Database = UATable{
 	"chrome": {
-		"37.0.2049.0",
+		"138.0.0.0",
 		Windows,
 		Formats{
+			"138": "Mozilla/5.0 ({{.OSN}} {{.OSV}}{{.Coms}}) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/{{.Ver}} Safari/537.36",
+			"137": "Mozilla/5.0 ({{.OSN}} {{.OSV}}{{.Coms}}) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/{{.Ver}} Safari/537.36",
 			"37": "Mozilla/5.0 ({{.OSN}} {{.OSV}}{{.Coms}}) Chrome/{{.Ver}} Safari/537.36",
 			"36": "Mozilla/5.0 ({{.OSN}} {{.OSV}}{{.Coms}}) Chrome/{{.Ver}} Safari/537.36",
 			"35": "Mozilla/5.0 ({{.OSN}} {{.OSV}}{{.Coms}}) Chrome/{{.Ver}} Safari/537.36",
@@ -89,9 +95,11 @@ var Database = UATable{
 		},
 	},
 	"firefox": {
-		"31.0",
+		"140.0",
 		Windows,
 		Formats{
+			"140": "Mozilla/5.0 ({{.OSN}} {{.OSV}}{{.Coms}}; rv:140.0) Gecko/20100101 Firefox/{{.Ver}}",
+			"139": "Mozilla/5.0 ({{.OSN}} {{.OSV}}{{.Coms}}; rv:139.0) Gecko/20100101 Firefox/{{.Ver}}",
 			"31": "Mozilla/5.0 ({{.OSN}} {{.OSV}}{{.Coms}}; rv:31.0) Gecko/20100101 Firefox/{{.Ver}}",
 			"30": "Mozilla/5.0 ({{.OSN}} {{.OSV}}{{.Coms}}; rv:30.0) Gecko/20120101 Firefox/{{.Ver}}",
 			"29": "Mozilla/5.0 ({{.OSN}} {{.OSV}}{{.Coms}}; rv:29.0) Gecko/20120101 Firefox/{{.Ver}}",
@@ -122,14 +130,26 @@ var Database = UATable{
 		},
 	},
 	"safari": {
-		"6.0",
+		"18.5",
 		Macintosh,
 		Formats{
+			"18": "Mozilla/5.0 (Macintosh; {{.OSN}} {{.OSV}}{{.Coms}}) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/{{.Ver}} Safari/605.1.15",
+			"17": "Mozilla/5.0 (Macintosh; {{.OSN}} {{.OSV}}{{.Coms}}) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/{{.Ver}} Safari/605.1.15",
 			"6": "Mozilla/5.0 (Macintosh; {{.OSN}} {{.OSV}}{{.Coms}}) AppleWebKit/536.26 (KHTML, like Gecko) Version/{{.Ver}} Safari/8536.25",
 			"5": "Mozilla/5.0 (Macintosh; {{.OSN}} {{.OSV}}{{.Coms}}) AppleWebKit/531.2+ (KHTML, like Gecko) Version/{{.Ver}} Safari/531.2+",
 			"4": "Mozilla/5.0 (Macintosh; {{.OSN}} {{.OSV}}{{.Coms}}) AppleWebKit/528.16 (KHTML, like Gecko) Version/{{.Ver}} Safari/528.16",
 		},
 	},
+	"mobile": {
+		"18.5",
+		IOS,
+		Formats{
+			"18": "Mozilla/5.0 (iPhone; {{.OSN}} {{.OSV}} like Mac OS X{{.Coms}}) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/{{.Ver}} Mobile/15E148 Safari/604.1",
+			"17": "Mozilla/5.0 (iPhone; {{.OSN}} {{.OSV}} like Mac OS X{{.Coms}}) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/{{.Ver}} Mobile/15E148 Safari/604.1",
+			"7": "Mozilla/5.0 (iPhone; {{.OSN}} {{.OSV}} like Mac OS X{{.Coms}}) AppleWebKit/537.51.2 (KHTML, like Gecko) Version/{{.Ver}} Mobile/11D257 Safari/9537.53",
+			"6": "Mozilla/5.0 (iPhone; {{.OSN}} {{.OSV}} like Mac OS X{{.Coms}}) AppleWebKit/536.26 (KHTML, like Gecko) Version/{{.Ver}} Mobile/10A5376e Safari/8536.25",
+		},
+	},
 	"itunes": {
 		"9.1.1",
 		Macintosh,
@@ -227,6 +247,11 @@ func Safari() string {
 	return createFromDefaults("Safari")
 }
 
+// Mobile returns a user agent string for the most recent version of the Mobile Safari browser.
+func Mobile() string {
+	return createFromDefaults("Mobile")
+}
+
 // AOL returns a user agent string for the most recent version of the AOL browser.
 func AOL() string {
 	return createFromDefaults("AOL")
@@ -272,6 +297,25 @@ func YahooBot() string {
 	return createFromDefaults("YahooBot")
 }
 
+// Pool returns a user agent string for the most recent version of each of the
+// popular desktop browsers, which are Chrome, Firefox and Safari.
+//
+// The returned slice is suitable for use as a browser user agent pool.
+func Pool() []string {
+	return []string{
+		Chrome(),
+		Firefox(),
+		Safari(),
+	}
+}
+
+// Random returns the user agent string for a randomly chosen browser from the
+// popular desktop browsers and Mobile Safari.
+func Random() string {
+	agents := append(Pool(), Mobile())
+	return agents[rand.Intn(len(agents))]
+}
+
 // Create generates and returns a complete user agent string.
 func Create() string {
 	return createFromDetails(Name, Version, OSName, OSVersion, Comments)
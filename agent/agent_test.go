@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"github.com/headzoo/ut"
 	"runtime"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -24,7 +26,7 @@ func TestCreate(t *testing.T) {
 	OSName = "Ubuntu"
 	OSVersion = "14.04"
 	Comments = []string{}
-	ut.AssertEquals("Mozilla/5.0 (Ubuntu 14.04) Chrome/37.0.2049.0 Safari/537.36", Create())
+	ut.AssertEquals("Mozilla/5.0 (Ubuntu 14.04) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/138.0.0.0 Safari/537.36", Create())
 
 	Name = "Firefox"
 	Version = "31.0"
@@ -83,30 +85,79 @@ func TestCreate(t *testing.T) {
 
 func TestChrome(t *testing.T) {
 	ut.Run(t)
-	ut.AssertEquals("Mozilla/5.0 (Windows NT 6.3; x64) Chrome/37.0.2049.0 Safari/537.36", Chrome())
+	ut.AssertEquals("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/138.0.0.0 Safari/537.36", Chrome())
+	ut.AssertTrue(majorVersion(Chrome(), "Chrome/") >= 120)
 }
 
 func TestFirefox(t *testing.T) {
 	ut.Run(t)
-	ut.AssertEquals("Mozilla/5.0 (Windows NT 6.3; x64; rv:31.0) Gecko/20100101 Firefox/31.0", Firefox())
+	ut.AssertEquals("Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:140.0) Gecko/20100101 Firefox/140.0", Firefox())
+	ut.AssertTrue(majorVersion(Firefox(), "Firefox/") >= 115)
 }
 
 func TestMSIE(t *testing.T) {
 	ut.Run(t)
-	ut.AssertEquals("Mozilla/5.0 (compatible; MSIE 10.0; Windows NT 6.3; x64; Trident/5.0; .NET CLR 3.5.30729)", MSIE())
+	ut.AssertEquals("Mozilla/5.0 (compatible; MSIE 10.0; Windows NT 10.0; Win64; x64; Trident/5.0; .NET CLR 3.5.30729)", MSIE())
 }
 
 func TestOpera(t *testing.T) {
 	ut.Run(t)
-	ut.AssertEquals("Opera/9.80 (Windows NT 6.3; U; x64) Presto/2.9.181 Version/12.14", Opera())
+	ut.AssertEquals("Opera/9.80 (Windows NT 10.0; U; Win64; x64) Presto/2.9.181 Version/12.14", Opera())
 }
 
 func TestSafari(t *testing.T) {
 	ut.Run(t)
-	ut.AssertEquals("Mozilla/5.0 (Macintosh; Intel Mac OS X 10_6_8) AppleWebKit/536.26 (KHTML, like Gecko) Version/6.0 Safari/8536.25", Safari())
+	ut.AssertEquals("Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.5 Safari/605.1.15", Safari())
+	ut.AssertTrue(majorVersion(Safari(), "Version/") >= 17)
+}
+
+func TestMobile(t *testing.T) {
+	ut.Run(t)
+	ut.AssertEquals("Mozilla/5.0 (iPhone; CPU iPhone OS 18_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.5 Mobile/15E148 Safari/604.1", Mobile())
+	ut.AssertTrue(majorVersion(Mobile(), "Version/") >= 17)
 }
 
 func TestAOL(t *testing.T) {
 	ut.Run(t)
-	ut.AssertEquals("Mozilla/5.0 (compatible; MSIE 9.0; AOL 9.7; AOLBuild 4343.19; Windows NT 6.3; WOW64; Trident/5.0; FunWebProducts; x64)", AOL())
+	ut.AssertEquals("Mozilla/5.0 (compatible; MSIE 9.0; AOL 9.7; AOLBuild 4343.19; Windows NT 10.0; WOW64; Trident/5.0; FunWebProducts; Win64; x64)", AOL())
+}
+
+func TestPool(t *testing.T) {
+	ut.Run(t)
+	pool := Pool()
+	ut.AssertEquals(3, len(pool))
+	ut.AssertEquals(Chrome(), pool[0])
+	ut.AssertEquals(Firefox(), pool[1])
+	ut.AssertEquals(Safari(), pool[2])
+	for _, ua := range pool {
+		ut.AssertFalse(strings.Contains(ua, "MSIE"))
+		ut.AssertFalse(strings.Contains(ua, "Presto"))
+	}
+}
+
+func TestRandom(t *testing.T) {
+	ut.Run(t)
+	known := strings.Join(append(Pool(), Mobile()), "\n")
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		ua := Random()
+		ut.AssertContains(ua, known)
+		seen[ua] = true
+	}
+	ut.AssertGreaterThan(1, len(seen))
+}
+
+// majorVersion returns the major version which follows the prefix in the user
+// agent, or 0 when the user agent does not contain the prefix.
+func majorVersion(ua, prefix string) int {
+	i := strings.Index(ua, prefix)
+	if i == -1 {
+		return 0
+	}
+	v := ua[i+len(prefix):]
+	if j := strings.IndexAny(v, ". "); j != -1 {
+		v = v[:j]
+	}
+	n, _ := strconv.Atoi(v)
+	return n
 }
@@ -52,6 +52,10 @@ type Assetable interface {
 
 	// Type describes the type of asset.
 	AssetType() AssetType
+
+	// DownloadWith writes the contents of the asset to the given writer using
+	// the session of the given browser.
+	DownloadWith(bow Browsable, out io.Writer) (int64, error)
 }
 
 // Asset implements Assetable.
@@ -81,6 +85,15 @@ func (at *Asset) AssetType() AssetType {
 	return at.Type
 }
 
+// DownloadWith writes the contents of the asset to the given writer using the
+// session of the given browser.
+//
+// Relative URLs are resolved against the browser page URL, and the browser
+// cookies and headers are sent with the request.
+func (at *Asset) DownloadWith(bow Browsable, out io.Writer) (int64, error) {
+	return bow.DownloadUrl(at.URL, out)
+}
+
 // Downloadable represents an asset that may be downloaded.
 type Downloadable interface {
 	Assetable
@@ -132,6 +145,23 @@ func NewLinkAsset(u *url.URL, id, text string) *Link {
 	}
 }
 
+// LinkResult is the result of requesting a link with CheckLinks().
+type LinkResult struct {
+	// URL is the absolute URL of the link.
+	URL *url.URL
+
+	// StatusCode is the status code of the response, or 0 when the request failed.
+	StatusCode int
+
+	// Err is the error returned when the request failed.
+	Err error
+}
+
+// Broken returns whether the request failed or received an error status.
+func (r LinkResult) Broken() bool {
+	return r.Err != nil || r.StatusCode >= 400
+}
+
 // Image stores the properties of an image.
 type Image struct {
 	DownloadableAsset
@@ -2,14 +2,34 @@ package browser
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/headzoo/surf/errors"
+	"github.com/headzoo/surf/event"
 	"github.com/headzoo/surf/jar"
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
 	"io"
+	"io/ioutil"
+	"math/rand"
+	"mime"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -28,42 +48,291 @@ const (
 
 	// FollowRedirectsAttribute instructs a Browser to follow Location headers.
 	FollowRedirects
+
+	// RandomUserAgent instructs a Browser to choose a random user agent from
+	// the user agent pool, instead of cycling through the pool in order.
+	RandomUserAgent
+
+	// SkipExternalAssets instructs SavePage() to skip assets which are not on
+	// the same host as the page.
+	SkipExternalAssets
+
+	// ParseHTMLOnly instructs a Browser to only parse responses with an HTML
+	// or XML content type. The body of any other response is kept as raw bytes
+	// instead of being parsed into a document.
+	ParseHTMLOnly
+
+	// CurlSensitiveHeaders instructs AsCurl() to include the Authorization and
+	// Proxy-Authorization headers, and the cookies sent with the request.
+	CurlSensitiveHeaders
+
+	// StripCrossHostHeaders instructs a Browser to remove the Authorization,
+	// Proxy-Authorization and Cookie headers when following a redirect to a
+	// host other than the host of the original request.
+	StripCrossHostHeaders
+
+	// ClientHints instructs a Browser to send the DNT and Sec-Fetch-* headers
+	// with each request, and the Sec-Ch-Ua client hints when the user agent is
+	// a Chromium based browser, like a web browser does. Only the requests
+	// which load a page are sent as navigations.
+	ClientHints
 )
 
+// RequestOptions are options which apply to a single request.
+type RequestOptions struct {
+	// CookieJar is used instead of the browser cookie jar when not nil. The jar
+	// is used for the request and any redirects it follows.
+	CookieJar http.CookieJar
+}
+
+// Stats are the counters of the requests made by a browser.
+type Stats struct {
+	// Requests is the number of requests sent, including retries and the
+	// requests for followed redirects.
+	Requests int64
+
+	// BytesRead is the number of bytes read from response bodies, including
+	// the bodies read from Stream() and DownloadUrl().
+	BytesRead int64
+
+	// Errors is the number of requests which failed.
+	Errors int64
+
+	// Redirects is the number of redirects followed.
+	Redirects int64
+}
+
+// RedirectPolicy decides whether a redirect is followed.
+//
+// The req is the request for the redirect destination, and via are the
+// requests already made, oldest first. Returning an error stops the redirect
+// from being followed.
+type RedirectPolicy func(req *http.Request, via []*http.Request) error
+
+// SameDomainRedirects is a RedirectPolicy which only follows redirects to the
+// same domain as the first request.
+func SameDomainRedirects(req *http.Request, via []*http.Request) error {
+	if len(via) > 0 && !strings.EqualFold(req.URL.Hostname(), via[0].URL.Hostname()) {
+		return errors.NewLocation(
+			"Redirect to another domain blocked. Cannot follow '%s'.", req.URL.String())
+	}
+	return nil
+}
+
+// Middleware is called with each request before it is sent.
+//
+// The middleware may change the request, eg to add headers or sign the request.
+// Returning an error stops the request from being sent.
+type Middleware func(req *http.Request) error
+
+// URLRewriter returns the URL a request is sent to in place of the given URL.
+//
+// The given URL is a copy which may be changed and returned. Returning nil
+// sends the request to the given URL.
+type URLRewriter func(u *url.URL) *url.URL
+
+// DocumentParser creates a document from the body of a response.
+//
+// A parser may read and change the body before parsing it, eg to fix the
+// character encoding or to clean up invalid markup.
+type DocumentParser func(resp *http.Response) (*goquery.Document, error)
+
+// DocumentFilter changes the document of each page after it has been parsed,
+// eg to remove ads or normalize the markup.
+type DocumentFilter func(doc *goquery.Document)
+
+// DefaultStripParams are common tracking query parameters which may be passed
+// to SetStripParams(). A trailing "*" matches any parameter with the prefix.
+var DefaultStripParams = []string{
+	"utm_*",
+	"fbclid",
+	"gclid",
+	"dclid",
+	"msclkid",
+	"mc_cid",
+	"mc_eid",
+}
+
+// DefaultContentType is the content type used by Post() when an empty content
+// type is given and SetDefaultContentType() has not been called.
+const DefaultContentType = "application/x-www-form-urlencoded"
+
 // InitialAssetsArraySize is the initial size when allocating a slice of page
 // assets. Increasing this size may lead to a very small performance increase
 // when downloading assets from a page with a lot of assets.
 var InitialAssetsSliceSize = 20
 
+// MaxMetaRefreshDelay is the longest time FollowMetaRefresh() waits before
+// following a refresh meta tag. There is no maximum when the value is 0.
+var MaxMetaRefreshDelay = 30 * time.Second
+
+// CheckLinksConcurrency is the number of links CheckLinks() requests at the
+// same time.
+var CheckLinksConcurrency = 4
+
 // Browsable represents an HTTP web browser.
 type Browsable interface {
+	event.Eventable
+
 	// SetUserAgent sets the user agent.
 	SetUserAgent(ua string)
 
+	// SetUserAgentPool sets a pool of user agents to choose from for each request.
+	SetUserAgentPool(agents []string)
+
 	// SetAttribute sets a browser instruction attribute.
 	SetAttribute(a Attribute, v bool)
 
 	// SetAttributes is used to set all the browser attributes.
 	SetAttributes(a AttributeMap)
 
+	// SetFollowRedirects sets whether redirects are followed.
+	SetFollowRedirects(follow bool)
+
+	// SetSendReferer sets whether the Referer header is sent.
+	SetSendReferer(send bool)
+
+	// SetMetaRefreshHandling sets whether the refresh meta tag is followed.
+	SetMetaRefreshHandling(handle bool)
+
+	// SetRetry sets the number of times failed requests are retried.
+	SetRetry(attempts int, backoff time.Duration)
+
+	// SetRetryStatusCodes sets the response status codes which cause a request to be retried.
+	SetRetryStatusCodes(codes []int)
+
+	// SetRateLimit sets the minimum time between requests to the same host.
+	SetRateLimit(d time.Duration)
+
+	// SetMaxResponseSize sets the maximum number of bytes read from a page response.
+	SetMaxResponseSize(n int64)
+
+	// SetCache sets the cache used for conditional GET requests.
+	SetCache(c jar.Cache)
+
+	// SetTransport sets the transport used to send requests.
+	SetTransport(rt http.RoundTripper)
+
+	// ForceHTTP1 sets whether requests are sent using HTTP/1.1 instead of HTTP/2.
+	ForceHTTP1(force bool)
+
+	// SetClientCertificate sets the certificate presented to servers which request one.
+	SetClientCertificate(cert tls.Certificate)
+
+	// SetMaxIdleConns sets the maximum number of idle connections kept for reuse.
+	SetMaxIdleConns(n int)
+
+	// SetMaxIdleConnsPerHost sets the maximum number of idle connections kept for reuse with each host.
+	SetMaxIdleConnsPerHost(n int)
+
+	// LoadClientCertificate loads the certificate presented to servers from PEM files.
+	LoadClientCertificate(certFile, keyFile string) error
+
+	// SetDryRun sets whether requests are built without being sent.
+	SetDryRun(dryRun bool)
+
+	// DryRunRequest returns the last request built in dry-run mode.
+	DryRunRequest() *http.Request
+
+	// SetLogger sets the logger which receives the browser log messages.
+	SetLogger(l Logger)
+
+	// SetLoggerWriter writes the browser log messages at or above the given level to w.
+	SetLoggerWriter(w io.Writer, lev LogLevel)
+
+	// SetDebug sets whether every browser log message is written to stderr.
+	SetDebug(debug bool)
+
+	// Use adds middleware which is called with each request before it is sent.
+	Use(m ...Middleware)
+
+	// SetRedirectPolicy sets the policy which decides whether a redirect is followed.
+	SetRedirectPolicy(p RedirectPolicy)
+
+	// SetURLRewriter sets the function which rewrites the URL each request is sent to.
+	SetURLRewriter(r URLRewriter)
+
+	// SetDocumentParser sets the parser which creates the document of each page.
+	SetDocumentParser(p DocumentParser)
+
+	// SetDocumentFilter sets the filters which change the document of each page.
+	SetDocumentFilter(filters ...DocumentFilter)
+
 	// SetBookmarksJar sets the bookmarks jar the browser uses.
 	SetBookmarksJar(bj jar.BookmarksJar)
 
 	// SetCookieJar is used to set the cookie jar the browser uses.
 	SetCookieJar(cj http.CookieJar)
 
+	// CookieJar returns the cookie jar the browser uses.
+	CookieJar() http.CookieJar
+
+	// DisableCookies stops the browser from storing and sending cookies.
+	DisableCookies()
+
+	// EnableCookies starts storing and sending cookies after DisableCookies() was called.
+	EnableCookies()
+
 	// SetHistoryJar is used to set the history jar the browser uses.
 	SetHistoryJar(hj jar.History)
 
+	// History returns the visited pages, starting with the oldest.
+	History() []*jar.State
+
+	// ClearHistory removes the previous pages from the history.
+	ClearHistory()
+
+	// Reset returns the browser to a fresh session.
+	Reset()
+
 	// SetHeadersJar sets the headers the browser sends with each request.
 	SetHeadersJar(h http.Header)
 
 	// AddRequestHeader adds a header the browser sends with each request.
 	AddRequestHeader(name, value string)
 
+	// SetStripParams sets the query parameters removed from the URLs the browser uses.
+	SetStripParams(params []string)
+
+	// SetAccept sets the Accept header sent with each request.
+	SetAccept(mediaTypes ...string)
+
+	// AcceptHTML sets the Accept header to prefer HTML documents.
+	AcceptHTML()
+
+	// AcceptJSON sets the Accept header to accept JSON documents.
+	AcceptJSON()
+
+	// AcceptXML sets the Accept header to accept XML documents.
+	AcceptXML()
+
+	// SetHostHeaders sets the headers the browser sends with requests to the given host.
+	SetHostHeaders(host string, h http.Header)
+
+	// SetBasicAuthForHost sets the basic auth credentials sent with requests to the given host.
+	SetBasicAuthForHost(host, user, pass string)
+
+	// SetReferer sets the Referer header sent with each request.
+	SetReferer(u string)
+
 	// Open requests the given URL using the GET method.
 	Open(url string) error
 
+	// OpenWithOptions requests the given URL using the GET method and the given options.
+	OpenWithOptions(url string, opts RequestOptions) error
+
+	// OpenAll requests each of the given URLs in parallel using clones of the browser.
+	OpenAll(urls []string, concurrency int) []error
+
+	// OpenReader loads the HTML read from r as the page with the given URL.
+	OpenReader(r io.Reader, baseURL string) error
+
+	// OpenContext requests the given URL using the GET method with the given context.
+	OpenContext(ctx context.Context, url string) error
+
+	// OpenDeadline requests the given URL using the GET method, and stops when it takes longer than d.
+	OpenDeadline(url string, d time.Duration) error
+
 	// OpenForm appends the data values to the given URL and sends a GET request.
 	OpenForm(url string, data url.Values) error
 
@@ -73,45 +342,120 @@ type Browsable interface {
 	// Post requests the given URL using the POST method.
 	Post(url string, contentType string, body io.Reader) error
 
+	// SetDefaultContentType sets the content type used by Post() when an empty content type is given.
+	SetDefaultContentType(ct string)
+
+	// PostContext requests the given URL using the POST method with the given context.
+	PostContext(ctx context.Context, url string, contentType string, body io.Reader) error
+
 	// PostForm requests the given URL using the POST method with the given data.
 	PostForm(url string, data url.Values) error
 
 	// PostMultipart requests the given URL using the POST method with the given data using multipart/form-data format.
 	PostMultipart(u string, data url.Values) error
 
+	// PostMultipartFiles requests the given URL using the POST method with the given fields and files using multipart/form-data format.
+	PostMultipartFiles(u string, fields url.Values, files map[string]string) error
+
+	// PostJSON requests the given URL using the POST method with the given value encoded as JSON.
+	PostJSON(u string, v interface{}) error
+
+	// PutJSON requests the given URL using the PUT method with the given value encoded as JSON.
+	PutJSON(u string, v interface{}) error
+
+	// PatchJSON requests the given URL using the PATCH method with the given value encoded as JSON.
+	PatchJSON(u string, v interface{}) error
+
+	// Options requests the given URL using the OPTIONS method.
+	Options(url string) (http.Header, error)
+
+	// Exists requests the given URL using the HEAD method and returns whether it exists.
+	Exists(url string) (bool, error)
+
+	// PollUntil requests the given URL until the page contains an element matching the selector.
+	PollUntil(url, selector string, interval, timeout time.Duration) error
+
+	// Stream requests the given URL using the GET method and returns the unread body.
+	Stream(url string) (io.ReadCloser, *http.Response, error)
+
+	// FollowMetaRefresh waits for and follows the refresh meta tag of the page.
+	FollowMetaRefresh() (bool, error)
+
+	// StopMetaRefresh cancels a pending refresh of the page.
+	StopMetaRefresh()
+
 	// Back loads the previously requested page.
 	Back() bool
 
+	// Forward loads the page which was left by calling Back().
+	Forward() bool
+
 	// Reload duplicates the last successful request.
 	Reload() error
 
+	// ReloadNoCache duplicates the last successful request without using the cache.
+	ReloadNoCache() error
+
 	// Bookmark saves the page URL in the bookmarks with the given name.
 	Bookmark(name string) error
 
 	// Click clicks on the page element matched by the given expression.
 	Click(expr string) error
 
+	// Next loads the page linked to by the rel="next" link of the page.
+	Next() error
+
+	// Prev loads the page linked to by the rel="prev" link of the page.
+	Prev() error
+
 	// Form returns the form in the current page that matches the given expr.
 	Form(expr string) (Submittable, error)
 
+	// FillForm returns the form matching the given expr with the given fields set.
+	FillForm(expr string, data url.Values) (Submittable, error)
+
+	// SubmitForm fills the form matching the given expr with the given fields and submits it.
+	SubmitForm(expr string, data url.Values) error
+
 	// Forms returns an array of every form in the page.
 	Forms() []Submittable
 
 	// Links returns an array of every link found in the page.
 	Links() []*Link
 
+	// CheckLinks requests every link in the page and returns the results.
+	CheckLinks() []LinkResult
+
 	// Images returns an array of every image found in the page.
 	Images() []*Image
 
+	// DownloadImages downloads every image found in the page to the given directory.
+	DownloadImages(dir string) (int, error)
+
+	// SavePage saves the page and its assets to the given directory.
+	SavePage(dir string) error
+
 	// Stylesheets returns an array of every stylesheet linked to the document.
 	Stylesheets() []*Stylesheet
 
 	// Scripts returns an array of every script linked to the document.
 	Scripts() []*Script
 
+	// Favicon returns the URL of the page favicon.
+	Favicon() (*url.URL, error)
+
+	// CanonicalURL returns the canonical URL declared by the page.
+	CanonicalURL() (*url.URL, error)
+
 	// SiteCookies returns the cookies for the current site.
 	SiteCookies() []*http.Cookie
 
+	// CookiesForURL returns the cookies the browser would send to the given URL.
+	CookiesForURL(u string) []*http.Cookie
+
+	// ExportCookies returns every cookie in the cookie jar with all of its attributes.
+	ExportCookies() []*http.Cookie
+
 	// ResolveUrl returns an absolute URL for a possibly relative URL.
 	ResolveUrl(u *url.URL) *url.URL
 
@@ -121,36 +465,135 @@ type Browsable interface {
 	// Download writes the contents of the document to the given writer.
 	Download(o io.Writer) (int64, error)
 
+	// DownloadUrl writes the contents of the given URL to the given writer.
+	DownloadUrl(u *url.URL, o io.Writer) (int64, error)
+
 	// Url returns the page URL as a string.
 	Url() *url.URL
 
+	// RequestedURL returns the URL which was requested to load the page.
+	RequestedURL() *url.URL
+
+	// WasRedirected returns whether redirects were followed to load the page.
+	WasRedirected() bool
+
 	// StatusCode returns the response status code.
 	StatusCode() int
 
 	// Title returns the page title.
 	Title() string
 
+	// Meta returns the content of the meta tag with the given name.
+	Meta(name string) string
+
+	// MetaProperty returns the content of the meta tag with the given property.
+	MetaProperty(property string) string
+
 	// ResponseHeaders returns the page headers.
 	ResponseHeaders() http.Header
 
+	// State returns the current browser state.
+	State() *jar.State
+
+	// SetState restores the browser state returned by State().
+	SetState(s *jar.State)
+
+	// Response returns the *http.Response for the page.
+	Response() *http.Response
+
+	// Protocol returns the protocol of the page response.
+	Protocol() string
+
+	// Language returns the language of the page as a BCP 47 tag.
+	Language() string
+
+	// AsCurl returns the request for the page as a curl command.
+	AsCurl() string
+
+	// ExportHAR writes the history and the current page to w in the HAR format.
+	ExportHAR(w io.Writer) error
+
+	// ContentType returns the media type of the page response.
+	ContentType() string
+
+	// IsHTML returns whether the page response is an HTML document.
+	IsHTML() bool
+
+	// IsJSON returns whether the page response is a JSON document.
+	IsJSON() bool
+
+	// AllowedMethods returns the methods listed in the Allow header of the page response.
+	AllowedMethods() []string
+
 	// Body returns the page body as a string of html.
 	Body() string
 
+	// RawBody returns the body of a page which was not parsed as HTML.
+	RawBody() []byte
+
+	// ResponseBytes returns the body of the page as bytes.
+	ResponseBytes() []byte
+
+	// BytesRead returns the number of bytes read from the body of the page.
+	BytesRead() int64
+
+	// TotalBytesRead returns the number of bytes read from response bodies by the browser.
+	TotalBytesRead() int64
+
+	// FromCache returns whether the page was served from the cache.
+	FromCache() bool
+
+	// Stats returns the counters of the requests made by the browser.
+	Stats() Stats
+
+	// Text returns the visible text of the page.
+	Text() string
+
+	// FindText returns the text of the first element matching the given expression.
+	FindText(expr string) string
+
+	// FindAttr returns an attribute of the first element matching the given expression.
+	FindAttr(expr, attr string) (string, bool)
+
+	// Each calls the given function with each element matching the given expression.
+	Each(expr string, fn func(i int, s *goquery.Selection))
+
 	// Dom returns the inner *goquery.Selection.
 	Dom() *goquery.Selection
 
+	// SetDom replaces the document of the page.
+	SetDom(sel *goquery.Selection) error
+
 	// Find returns the dom selections matching the given expression.
 	Find(expr string) *goquery.Selection
 }
 
 // Default is the default Browser implementation.
+//
+// A Browser may be used from multiple goroutines. Requests are sent one at a
+// time, and reading the page is safe while a request is being sent. The Set*
+// methods should be called before the browser is shared between goroutines.
 type Browser struct {
+	event.Dispatcher
+
+	// mu guards the state, history, refresh, refreshSeq, userAgentIndex, and stats fields.
+	mu sync.RWMutex
+
+	// requestMu serializes the requests made by httpRequest.
+	requestMu sync.Mutex
+
 	// state is the current browser state.
 	state *jar.State
 
 	// userAgent is the User-Agent header value sent with requests.
 	userAgent string
 
+	// userAgents is a pool of User-Agent header values used instead of userAgent.
+	userAgents []string
+
+	// userAgentIndex is the index of the next value in userAgents.
+	userAgentIndex int
+
 	// cookies stores cookies for every site visited by the browser.
 	cookies http.CookieJar
 
@@ -160,14 +603,205 @@ type Browser struct {
 	// history stores the visited pages.
 	history jar.History
 
+	// forward stores the pages left by calling Back(), most recent last.
+	forward []*jar.State
+
 	// headers are additional headers to send with each request.
 	headers http.Header
 
+	// hostHeaders are additional headers to send with requests to each host.
+	hostHeaders map[string]http.Header
+
+	// referer is sent as the Referer header instead of the previous page URL
+	// when not empty.
+	referer string
+
 	// attributes is the set browser attributes.
 	attributes AttributeMap
 
 	// refresh is a timer used to meta refresh pages.
 	refresh *time.Timer
+
+	// refreshSeq identifies the current refresh timer.
+	refreshSeq int
+
+	// retryAttempts is the number of times a failed request is retried.
+	retryAttempts int
+
+	// retryBackoff is the delay before the first retry.
+	retryBackoff time.Duration
+
+	// retryStatusCodes are the response status codes which cause a retry.
+	retryStatusCodes []int
+
+	// rateLimit is the minimum time between requests to the same host.
+	rateLimit time.Duration
+
+	// limiter records the requests made to each host for the rate limit.
+	limiter *rateLimiter
+
+	// bytesRead is the number of bytes read from response bodies.
+	bytesRead int64
+
+	// requests is the number of requests sent.
+	requests int64
+
+	// errors is the number of requests which failed.
+	errors int64
+
+	// redirects is the number of redirects followed.
+	redirects int64
+
+	// maxResponseSize is the maximum number of bytes read from a page response.
+	maxResponseSize int64
+
+	// cache stores responses for conditional GET requests when not nil.
+	cache jar.Cache
+
+	// transport is the transport set with SetTransport.
+	transport http.RoundTripper
+
+	// forceHTTP1 disables HTTP/2 when true.
+	forceHTTP1 bool
+
+	// dryRun prevents requests from being sent when true.
+	dryRun bool
+
+	// dryRunRequest is the last request built in dry-run mode.
+	dryRunRequest *http.Request
+
+	// clientCerts are the certificates presented to servers which request one.
+	clientCerts []tls.Certificate
+
+	// maxIdleConns is the maximum number of idle connections, or 0 to use the
+	// value of the transport.
+	maxIdleConns int
+
+	// maxIdleConnsPerHost is the maximum number of idle connections per host,
+	// or 0 to use the value of the transport.
+	maxIdleConnsPerHost int
+
+	// clientTransport is the transport used by the client, or nil to use the
+	// default transport.
+	clientTransport http.RoundTripper
+
+	// middleware is called in order with each request before it is sent.
+	middleware []Middleware
+
+	// redirectPolicy decides whether a redirect is followed when not nil.
+	redirectPolicy RedirectPolicy
+
+	// urlRewriter rewrites the URL each request is sent to when not nil.
+	urlRewriter URLRewriter
+
+	// parser creates the document of each page when not nil.
+	parser DocumentParser
+
+	// filters change the document of each page in order.
+	filters []DocumentFilter
+
+	// stripParams are the query parameters removed from URLs.
+	stripParams []string
+
+	// contentType is used by Post() when an empty content type is given.
+	contentType string
+
+	// logger receives log messages when not nil.
+	logger Logger
+}
+
+// Clone creates and returns a copy of the browser.
+//
+// The clone has the same user agents, headers, attributes, middleware, redirect
+// policy, retry, rate limit, response size and transport settings, and a copy
+// of the bookmarks. It starts on a copy of the current page with an empty
+// history, and event handlers are not copied. Changes to the document of either
+// page do not change the other. The response cache is shared with the clone,
+// and requests made by the clone count towards the rate limit of the browser.
+//
+// The clone is given a new memory cookie jar containing a copy of every cookie
+// in the browser, with all of their attributes, when the cookie jar is a
+// jar.CookiesJar. Otherwise the clone is only given the cookies for the current
+// page. The cookie jar can be shared instead by passing the original to the
+// clone, eg clone.SetCookieJar(bow.CookieJar()).
+func (bow *Browser) Clone() *Browser {
+	limiter := bow.rateLimiter()
+	bow.mu.RLock()
+	defer bow.mu.RUnlock()
+
+	c := &Browser{
+		limiter:             limiter,
+		state:               cloneState(bow.state),
+		userAgent:           bow.userAgent,
+		userAgents:          append([]string(nil), bow.userAgents...),
+		cookies:             jar.NewMemoryCookies(),
+		history:             jar.NewMemoryHistory(),
+		headers:             copyHeaders(bow.headers),
+		hostHeaders:         make(map[string]http.Header, len(bow.hostHeaders)),
+		referer:             bow.referer,
+		attributes:          make(AttributeMap, len(bow.attributes)),
+		retryAttempts:       bow.retryAttempts,
+		retryBackoff:        bow.retryBackoff,
+		rateLimit:           bow.rateLimit,
+		maxResponseSize:     bow.maxResponseSize,
+		cache:               bow.cache,
+		transport:           bow.transport,
+		forceHTTP1:          bow.forceHTTP1,
+		dryRun:              bow.dryRun,
+		clientCerts:         bow.clientCerts,
+		maxIdleConns:        bow.maxIdleConns,
+		maxIdleConnsPerHost: bow.maxIdleConnsPerHost,
+		clientTransport:     bow.clientTransport,
+		middleware:          append([]Middleware(nil), bow.middleware...),
+		redirectPolicy:      bow.redirectPolicy,
+		urlRewriter:         bow.urlRewriter,
+		parser:              bow.parser,
+		filters:             append([]DocumentFilter(nil), bow.filters...),
+		stripParams:         append([]string(nil), bow.stripParams...),
+		contentType:         bow.contentType,
+	}
+	for a, v := range bow.attributes {
+		c.attributes[a] = v
+	}
+	for host, h := range bow.hostHeaders {
+		c.hostHeaders[host] = copyHeaders(h)
+	}
+	if bow.retryStatusCodes != nil {
+		c.retryStatusCodes = append([]int{}, bow.retryStatusCodes...)
+	}
+	bookmarks := jar.NewMemoryBookmarks()
+	if bmj, ok := bow.bookmarks.(jar.BookmarksMetadataJar); ok {
+		for name := range bmj.All() {
+			if bm, err := bmj.ReadBookmark(name); err == nil {
+				bookmarks.SaveBookmark(name, bm)
+			}
+		}
+	} else if bow.bookmarks != nil {
+		for name, u := range bow.bookmarks.All() {
+			bookmarks.Save(name, u)
+		}
+	}
+	c.bookmarks = bookmarks
+	if cj, ok := bow.cookies.(jar.CookiesJar); ok {
+		c.cookies = jar.CopyCookies(cj)
+	} else if bow.cookies != nil && bow.state != nil && bow.state.Request != nil {
+		u := bow.state.Request.URL
+		c.cookies.SetCookies(u, bow.cookies.Cookies(u))
+	}
+
+	return c
+}
+
+// cloneState returns a copy of the given state with a copy of its document.
+func cloneState(st *jar.State) *jar.State {
+	if st == nil {
+		return nil
+	}
+	c := *st
+	if st.Dom != nil {
+		c.Dom = goquery.CloneDocument(st.Dom)
+	}
+	return &c
 }
 
 // Open requests the given URL using the GET method.
@@ -179,17 +813,146 @@ func (bow *Browser) Open(u string) error {
 	return bow.httpGET(ur, nil)
 }
 
+// OpenWithOptions requests the given URL using the GET method and the given options.
+//
+// The options only apply to this request. For example a temporary cookie jar
+// can be used to make a request without changing the browser cookies.
+//
+//	err := bow.OpenWithOptions(u, browser.RequestOptions{
+//		CookieJar: jar.NewMemoryCookies(),
+//	})
+func (bow *Browser) OpenWithOptions(u string, opts RequestOptions) error {
+	ur, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	req, err := bow.buildRequest(navigateRequest, "GET", ur.String(), nil, nil)
+	if err != nil {
+		return err
+	}
+	return bow.httpRequestWithOptions(req, opts)
+}
+
+// OpenAll requests each of the given URLs in parallel using clones of the browser.
+//
+// Each URL is opened by a new clone, so the browser itself is not changed, and
+// no more than concurrency URLs are requested at the same time. The clones
+// share the rate limit of the browser, and are given the event handlers of the
+// browser, which may be called from several goroutines at the same time.
+// Returns the errors in the same order as the URLs, where the error is nil when
+// the URL was opened successfully.
+func (bow *Browser) OpenAll(urls []string, concurrency int) []error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	errs := make([]error, len(urls))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, u string) {
+			defer wg.Done()
+			c := bow.Clone()
+			bow.Dispatcher.CopyTo(&c.Dispatcher)
+			errs[i] = c.Open(u)
+			<-sem
+		}(i, u)
+	}
+	wg.Wait()
+	return errs
+}
+
+// OpenReader loads the HTML read from r as the page with the given URL.
+//
+// No request is sent. The page is given a GET request for the base URL and an
+// empty response with a 200 status code, so relative links, forms and assets
+// are resolved against the base URL. The previous page is added to the
+// history, and no events are dispatched.
+func (bow *Browser) OpenReader(r io.Reader, baseURL string) error {
+	req, err := http.NewRequest("GET", baseURL, nil)
+	if err != nil {
+		return err
+	}
+	dom, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return err
+	}
+	dom.Url = req.URL
+	resp := &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+
+	bow.requestMu.Lock()
+	defer bow.requestMu.Unlock()
+	bow.preSend()
+	st := jar.NewHistoryState(req, resp, dom)
+	st.Time = time.Now()
+	bow.mu.Lock()
+	bow.history.Push(bow.state)
+	bow.state = st
+	bow.forward = nil
+	bow.mu.Unlock()
+	return nil
+}
+
 // OpenForm appends the data values to the given URL and sends a GET request.
+//
+// The data values are merged with the query string already in the URL. A data
+// value replaces every value in the query string with the same key.
 func (bow *Browser) OpenForm(u string, data url.Values) error {
 	ul, err := url.Parse(u)
 	if err != nil {
 		return err
 	}
-	ul.RawQuery = data.Encode()
+	query := ul.Query()
+	for k, vs := range data {
+		query[k] = vs
+	}
+	ul.RawQuery = query.Encode()
 
 	return bow.Open(ul.String())
 }
 
+// OpenContext requests the given URL using the GET method with the given context.
+//
+// The request is stopped when the context is cancelled, including while waiting
+// to retry the request.
+func (bow *Browser) OpenContext(ctx context.Context, u string) error {
+	ur, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	req, err := bow.buildRequest(navigateRequest, "GET", ur.String(), nil, nil)
+	if err != nil {
+		return err
+	}
+	return bow.httpRequest(req.WithContext(ctx))
+}
+
+// OpenDeadline requests the given URL using the GET method, and stops when it takes longer than d.
+//
+// The duration bounds the whole navigation, including following redirects,
+// retries and reading the page. Returns a Timeout error when the page is not
+// loaded in time, and the browser state is not changed. A meta refresh of the
+// page is followed after OpenDeadline returns, and is not bounded by d.
+func (bow *Browser) OpenDeadline(u string, d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	err := bow.OpenContext(ctx, u)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return errors.NewTimeout("Cannot open '%s' within %s.", u, d)
+	}
+	return err
+}
+
 // OpenBookmark calls Open() with the URL for the bookmark with the given name.
 func (bow *Browser) OpenBookmark(name string) error {
 	url, err := bow.bookmarks.Read(name)
@@ -200,6 +963,9 @@ func (bow *Browser) OpenBookmark(name string) error {
 }
 
 // Post requests the given URL using the POST method.
+//
+// The default content type is used when contentType is empty. See
+// SetDefaultContentType().
 func (bow *Browser) Post(u string, contentType string, body io.Reader) error {
 	ur, err := url.Parse(u)
 	if err != nil {
@@ -208,52 +974,358 @@ func (bow *Browser) Post(u string, contentType string, body io.Reader) error {
 	return bow.httpPOST(ur, nil, contentType, body)
 }
 
-// PostForm requests the given URL using the POST method with the given data.
-func (bow *Browser) PostForm(u string, data url.Values) error {
-	return bow.Post(u, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
-}
-
-// PostMultipart requests the given URL using the POST method with the given data using multipart/form-data format.
-func (bow *Browser) PostMultipart(u string, data url.Values) error {
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	for k, vs := range data {
-		for _, v := range vs {
-			writer.WriteField(k, v)
-		}
+// PostContext requests the given URL using the POST method with the given context.
+//
+// The request is stopped when the context is cancelled, including while waiting
+// to retry the request.
+func (bow *Browser) PostContext(ctx context.Context, u string, contentType string, body io.Reader) error {
+	ur, err := url.Parse(u)
+	if err != nil {
+		return err
 	}
-	err := writer.Close()
+	req, err := bow.buildRequest(navigateRequest, "POST", ur.String(), nil, body)
 	if err != nil {
 		return err
-
 	}
-	return bow.Post(u, writer.FormDataContentType(), body)
+	req.Header.Set("Content-Type", bow.postContentType(contentType))
+	return bow.httpRequest(req.WithContext(ctx))
 }
 
-// Back loads the previously requested page.
+// SetDefaultContentType sets the content type used by Post() when an empty content type is given.
 //
-// Returns a boolean value indicating whether a previous page existed, and was
-// successfully loaded.
-func (bow *Browser) Back() bool {
-	if bow.history.Len() > 1 {
-		bow.state = bow.history.Pop()
-		return true
-	}
-	return false
+// The content type is also used by PostContext(). An explicit content type
+// always overrides the default. Setting an empty content type restores
+// DefaultContentType, which is the default.
+func (bow *Browser) SetDefaultContentType(ct string) {
+	bow.contentType = ct
+}
+
+// PostForm requests the given URL using the POST method with the given data.
+func (bow *Browser) PostForm(u string, data url.Values) error {
+	return bow.Post(u, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
+}
+
+// PostJSON requests the given URL using the POST method with the given value encoded as JSON.
+//
+// The value is encoded with json.Marshal() and sent with the
+// "application/json" content type.
+func (bow *Browser) PostJSON(u string, v interface{}) error {
+	return bow.httpJSON("POST", u, v)
+}
+
+// PutJSON requests the given URL using the PUT method with the given value encoded as JSON.
+//
+// The value is encoded the same way as PostJSON().
+func (bow *Browser) PutJSON(u string, v interface{}) error {
+	return bow.httpJSON("PUT", u, v)
+}
+
+// PatchJSON requests the given URL using the PATCH method with the given value encoded as JSON.
+//
+// The value is encoded the same way as PostJSON().
+func (bow *Browser) PatchJSON(u string, v interface{}) error {
+	return bow.httpJSON("PATCH", u, v)
+}
+
+// PostMultipart requests the given URL using the POST method with the given data using multipart/form-data format.
+func (bow *Browser) PostMultipart(u string, data url.Values) error {
+	body, contentType, err := multipartBody(data)
+	if err != nil {
+		return err
+	}
+	return bow.Post(u, contentType, body)
+}
+
+// PostMultipartFiles requests the given URL using the POST method with the given fields and files using multipart/form-data format.
+//
+// The files map the name of each form field to the path of the file to upload.
+// The files are streamed to the server instead of being read into memory, and
+// are read again when the request is redirected or retried.
+func (bow *Browser) PostMultipartFiles(u string, fields url.Values, files map[string]string) error {
+	ur, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		if _, err := os.Stat(name); err != nil {
+			return err
+		}
+	}
+	boundary := multipart.NewWriter(nil).Boundary()
+	getBody := func() (io.ReadCloser, error) {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(writeMultipart(pw, boundary, fields, files))
+		}()
+		return pr, nil
+	}
+
+	req, err := bow.buildRequest(navigateRequest, "POST", ur.String(), nil, nil)
+	if err != nil {
+		return err
+	}
+	body, _ := getBody()
+	defer body.Close()
+	req.Body = body
+	req.GetBody = getBody
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+
+	return bow.httpRequest(req)
+}
+
+// Options requests the given URL using the OPTIONS method.
+//
+// The response becomes the current page, but the response body is not parsed.
+// Returns the response headers.
+func (bow *Browser) Options(u string) (http.Header, error) {
+	ur, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	req, err := bow.buildRequest(fetchRequest, "OPTIONS", ur.String(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = bow.httpRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return bow.ResponseHeaders(), nil
+}
+
+// Exists requests the given URL using the HEAD method and returns whether it exists.
+//
+// The URL exists when the response has a 2xx status code. Any other status code
+// returns false without an error, and an error is only returned when the request
+// could not be made. The page state and history are not changed.
+func (bow *Browser) Exists(u string) (bool, error) {
+	ur, err := url.Parse(u)
+	if err != nil {
+		return false, err
+	}
+	req, err := bow.buildRequest(fetchRequest, "HEAD", bow.ResolveUrl(ur).String(), bow.Url(), nil)
+	if err != nil {
+		return false, err
+	}
+	req, err = bow.prepareRequest(req)
+	if err != nil {
+		return false, err
+	}
+	resp, err := bow.sendRequest(req, RequestOptions{})
+	if err != nil {
+		return false, bow.requestError(req, err)
+	}
+	bow.dispatchCookies(resp)
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 300, nil
+}
+
+// PollUntil requests the given URL until the page contains an element matching the selector.
+//
+// The URL is requested again every interval until the selector matches, which
+// is useful for server rendered pages which eventually produce content. Each
+// request is subject to the rate limit. Returns a Timeout error when the
+// selector does not match within the timeout, or the error of a failed request.
+func (bow *Browser) PollUntil(u, selector string, interval, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	timedOut := errors.NewTimeout(
+		"Element matching '%s' not found in '%s' after %s.", selector, u, timeout)
+	for {
+		err := bow.OpenContext(ctx, u)
+		if err != nil {
+			if ctx.Err() != nil {
+				return timedOut
+			}
+			return err
+		}
+		if bow.Find(selector).Length() > 0 {
+			return nil
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return timedOut
+		}
+	}
+}
+
+// Stream requests the given URL using the GET method and returns the unread body.
+//
+// The body is not parsed or buffered, which makes Stream suitable for large
+// downloads. The caller must close the body. Returns a PageNotFound error and
+// the response when the response has a 4xx or 5xx status code, in which case
+// the body has already been closed. The page state and history are not changed.
+func (bow *Browser) Stream(u string) (io.ReadCloser, *http.Response, error) {
+	ur, err := url.Parse(u)
+	if err != nil {
+		return nil, nil, err
+	}
+	ur = bow.ResolveUrl(ur)
+	req, err := bow.buildRequest(fetchRequest, "GET", ur.String(), bow.Url(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err = bow.prepareRequest(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := bow.sendRequest(req, RequestOptions{})
+	if err != nil {
+		return nil, nil, bow.requestError(req, err)
+	}
+	bow.dispatchCookies(resp)
+	if resp.StatusCode >= 400 {
+		resp.Body.Close()
+		return nil, resp, bow.requestError(req, errors.NewPageNotFound(
+			"Received status %d for '%s'.", resp.StatusCode, ur.String()))
+	}
+
+	resp.Body = &streamReader{ReadCloser: resp.Body, bow: bow}
+	return resp.Body, resp, nil
+}
+
+// FollowMetaRefresh waits for and follows the refresh meta tag of the page.
+//
+// The browser waits the number of seconds given by the tag, up to a maximum of
+// MaxMetaRefreshDelay, and then loads the URL given by the tag or reloads the
+// page. Any pending refresh from the MetaRefreshHandling attribute is stopped
+// first, so the page is not refreshed twice.
+//
+// Returns a boolean value indicating whether the browser followed the refresh.
+// An error is returned when a page has not been loaded, or the refresh fails.
+func (bow *Browser) FollowMetaRefresh() (bool, error) {
+	if !bow.loaded() {
+		return false, errors.NewPageNotLoaded("Cannot follow the meta refresh.")
+	}
+	delay, u, ok := bow.metaRefresh()
+	if !ok {
+		return false, nil
+	}
+	bow.StopMetaRefresh()
+	if MaxMetaRefreshDelay > 0 && delay > MaxMetaRefreshDelay {
+		delay = MaxMetaRefreshDelay
+	}
+	time.Sleep(delay)
+
+	err := bow.followRefresh(u)
+	return err == nil, err
+}
+
+// StopMetaRefresh cancels a pending refresh of the page.
+//
+// The page is refreshed in the background when the MetaRefreshHandling
+// attribute is set. The pending refresh is also cancelled whenever the browser
+// makes another request.
+func (bow *Browser) StopMetaRefresh() {
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	if bow.refresh != nil {
+		bow.refresh.Stop()
+		bow.refresh = nil
+	}
+}
+
+// Back loads the previously requested page.
+//
+// Returns a boolean value indicating whether a previous page existed, and was
+// successfully loaded.
+//
+// The event.Back event is dispatched with the state of the previous page when
+// the browser went back.
+func (bow *Browser) Back() bool {
+	bow.mu.Lock()
+	if bow.history.Len() == 0 || bow.history.Top() == nil {
+		bow.mu.Unlock()
+		return false
+	}
+	bow.forward = append(bow.forward, bow.state)
+	bow.state = bow.history.Pop()
+	st := bow.state
+	bow.mu.Unlock()
+
+	bow.Do(event.Back, st)
+	return true
+}
+
+// Forward loads the page which was left by calling Back().
+//
+// Returns a boolean value indicating whether a next page existed, and was
+// successfully loaded. Loading a new page removes the next pages. The
+// event.Forward event is dispatched with the state of the next page when the
+// browser went forward.
+func (bow *Browser) Forward() bool {
+	bow.mu.Lock()
+	if len(bow.forward) == 0 {
+		bow.mu.Unlock()
+		return false
+	}
+	bow.history.Push(bow.state)
+	bow.state = bow.forward[len(bow.forward)-1]
+	bow.forward = bow.forward[:len(bow.forward)-1]
+	st := bow.state
+	bow.mu.Unlock()
+
+	bow.Do(event.Forward, st)
+	return true
 }
 
 // Reload duplicates the last successful request.
+//
+// The cached response is used when the page has not been modified.
 func (bow *Browser) Reload() error {
-	if bow.state.Request != nil {
-		return bow.httpRequest(bow.state.Request)
+	return bow.reload(false)
+}
+
+// ReloadNoCache duplicates the last successful request without using the cache.
+//
+// The request is sent with the "Cache-Control: no-cache" and "Pragma: no-cache"
+// headers and without the conditional headers, so the page is always
+// requested from the server, like the hard refresh of a web browser.
+func (bow *Browser) ReloadNoCache() error {
+	return bow.reload(true)
+}
+
+// reload duplicates the last successful request, with the no-cache headers
+// when noCache is true.
+func (bow *Browser) reload(noCache bool) error {
+	st := bow.currentState()
+	if st == nil {
+		return errors.NewPageNotLoaded("Cannot reload, no page has been loaded.")
+	}
+	if st.Request == nil {
+		return errors.NewPageNotLoaded("Cannot reload, the previous request failed.")
+	}
+	req := st.Request.Clone(context.Background())
+	req.Response = nil
+	headers := bow.requestHeaders()
+	for _, name := range []string{"Cache-Control", "Pragma"} {
+		req.Header.Del(name)
+		if v, ok := headers[name]; ok {
+			req.Header[name] = append([]string(nil), v...)
+		}
+	}
+	if noCache {
+		req.Header.Set("Cache-Control", "no-cache")
+		req.Header.Set("Pragma", "no-cache")
 	}
-	return errors.NewPageNotLoaded("Cannot reload, the previous request failed.")
+	return bow.httpRequest(req)
 }
 
 // Bookmark saves the page URL in the bookmarks with the given name.
+//
+// The page title is saved with the URL when the bookmarks jar is a
+// jar.BookmarksMetadataJar.
 func (bow *Browser) Bookmark(name string) error {
-	return bow.bookmarks.Save(name, bow.ResolveUrl(bow.Url()).String())
+	if !bow.loaded() {
+		return errors.NewPageNotLoaded("Cannot bookmark, no page has been loaded.")
+	}
+	u := bow.ResolveUrl(bow.Url()).String()
+	if bmj, ok := bow.bookmarks.(jar.BookmarksMetadataJar); ok {
+		return bmj.SaveBookmark(name, jar.Bookmark{URL: u, Title: bow.Title()})
+	}
+	return bow.bookmarks.Save(name, u)
 }
 
 // Click clicks on the page element matched by the given expression.
@@ -262,6 +1334,9 @@ func (bow *Browser) Bookmark(name string) error {
 // to load the page pointed at by the link. Future versions of Surf may support
 // JavaScript and clicking on elements will fire the click event.
 func (bow *Browser) Click(expr string) error {
+	if !bow.parsed() {
+		return errors.NewPageNotParsed("Cannot click '%s'.", expr)
+	}
 	sel := bow.Find(expr)
 	if sel.Length() == 0 {
 		return errors.NewElementNotFound(
@@ -280,8 +1355,27 @@ func (bow *Browser) Click(expr string) error {
 	return bow.httpGET(href, bow.Url())
 }
 
+// Next loads the page linked to by the rel="next" link of the page.
+//
+// The link is found in either the link elements in the head of the page, or
+// the anchors in the body. Returns an ElementNotFound error when the page does
+// not have a next link.
+func (bow *Browser) Next() error {
+	return bow.followRel("next")
+}
+
+// Prev loads the page linked to by the rel="prev" link of the page.
+//
+// The link is found the same way as Next(), and rel="previous" is also used.
+func (bow *Browser) Prev() error {
+	return bow.followRel("prev", "previous")
+}
+
 // Form returns the form in the current page that matches the given expr.
 func (bow *Browser) Form(expr string) (Submittable, error) {
+	if !bow.parsed() {
+		return nil, errors.NewPageNotParsed("Cannot find the form '%s'.", expr)
+	}
 	sel := bow.Find(expr)
 	if sel.Length() == 0 {
 		return nil, errors.NewElementNotFound(
@@ -295,6 +1389,49 @@ func (bow *Browser) Form(expr string) (Submittable, error) {
 	return NewForm(bow, sel), nil
 }
 
+// FillForm returns the form in the current page that matches the given expr
+// with the given fields set, ready to be submitted.
+//
+// Every value of a field replaces the values of the field in the form.
+// Returns an ElementNotFound error when the form does not contain one of the
+// fields, and no fields are set.
+func (bow *Browser) FillForm(expr string, data url.Values) (Submittable, error) {
+	form, err := bow.Form(expr)
+	if err != nil {
+		return nil, err
+	}
+	f := form.(*Form)
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := f.fields[name]; !ok {
+			return nil, errors.NewElementNotFound(
+				"No input found with name '%s'.", name)
+		}
+	}
+	for _, name := range names {
+		f.fields[name] = append([]string(nil), data[name]...)
+	}
+
+	return f, nil
+}
+
+// SubmitForm fills the form in the current page that matches the given expr
+// with the given fields, and submits it.
+//
+// The form is filled like FillForm() does, and nothing is submitted when the
+// form is not found or does not contain one of the fields.
+func (bow *Browser) SubmitForm(expr string, data url.Values) error {
+	f, err := bow.FillForm(expr, data)
+	if err != nil {
+		return err
+	}
+	return f.Submit()
+}
+
 // Forms returns an array of every form in the page.
 func (bow *Browser) Forms() []Submittable {
 	sel := bow.Find("form")
@@ -327,6 +1464,70 @@ func (bow *Browser) Links() []*Link {
 	return links
 }
 
+// CheckLinks requests every link in the page and returns the results.
+//
+// Each HTTP and HTTPS link is requested once using the HEAD method, with the
+// browser cookies and headers, and the results are returned in the order the
+// links appear in the page. Up to CheckLinksConcurrency links are requested at
+// the same time, and the rate limit set with SetRateLimit() is respected. The
+// browser state is not changed.
+func (bow *Browser) CheckLinks() []LinkResult {
+	seen := make(map[string]bool)
+	urls := make([]*url.URL, 0, InitialAssetsSliceSize)
+	for _, link := range bow.Links() {
+		u := *link.URL
+		u.Fragment = ""
+		if (u.Scheme != "http" && u.Scheme != "https") || seen[u.String()] {
+			continue
+		}
+		seen[u.String()] = true
+		urls = append(urls, &u)
+	}
+
+	concurrency := CheckLinksConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	ref := bow.Url()
+	results := make([]LinkResult, len(urls))
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for i, u := range urls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, u *url.URL) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = bow.checkLink(u, ref)
+		}(i, u)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// checkLink requests the given URL using the HEAD method.
+func (bow *Browser) checkLink(u, ref *url.URL) LinkResult {
+	result := LinkResult{URL: u}
+	req, err := bow.buildRequest(fetchRequest, "HEAD", u.String(), ref, nil)
+	if err == nil {
+		req, err = bow.prepareRequest(req)
+	}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	resp, err := bow.sendRequest(req, RequestOptions{})
+	if err != nil {
+		result.Err = bow.requestError(req, err)
+		return result
+	}
+	resp.Body.Close()
+	bow.dispatchCookies(resp)
+	result.StatusCode = resp.StatusCode
+	return result
+}
+
 // Images returns an array of every image found in the page.
 func (bow *Browser) Images() []*Image {
 	images := make([]*Image, 0, InitialAssetsSliceSize)
@@ -345,6 +1546,115 @@ func (bow *Browser) Images() []*Image {
 	return images
 }
 
+// DownloadImages downloads every image found in the page to the given directory.
+//
+// The images are fetched using the browser cookies and headers, and each file
+// is named after the last element of the image URL path. Images embedded with
+// data URIs are skipped. A failed download does not stop the remaining images
+// from being downloaded.
+//
+// Returns the number of images downloaded, and an error describing every
+// failed download.
+func (bow *Browser) DownloadImages(dir string) (int, error) {
+	count := 0
+	failed := make([]string, 0)
+	for _, img := range bow.Images() {
+		if img.URL.Scheme == "data" {
+			continue
+		}
+		err := bow.downloadToDir(img.URL, dir)
+		if err != nil {
+			failed = append(failed, err.Error())
+			continue
+		}
+		count++
+	}
+
+	if len(failed) > 0 {
+		return count, errors.New(
+			"Failed to download %d image(s): %s", len(failed), strings.Join(failed, "; "))
+	}
+	return count, nil
+}
+
+// SavePage saves the page and its assets to the given directory.
+//
+// The page is written to "index.html", and the images, stylesheets, and scripts
+// it references are downloaded to the same directory. The references in the
+// saved page are rewritten to the downloaded files, which are given unique
+// names when more than one asset has the same name. The directory is created
+// when it does not exist.
+//
+// Assets embedded with data URIs are not downloaded. Assets on other hosts are
+// not downloaded when the SkipExternalAssets attribute is set, and the saved
+// page keeps their absolute URLs. A failed download does not stop the other
+// assets from being saved.
+//
+// Returns an error describing every failed download.
+func (bow *Browser) SavePage(dir string) error {
+	if !bow.loaded() {
+		return errors.NewPageNotLoaded("Cannot save the page.")
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	bow.mu.RLock()
+	dom := bow.state.Dom.Clone()
+	bow.mu.RUnlock()
+	page := bow.Url()
+
+	names := map[string]bool{"index.html": true}
+	saved := make(map[string]string)
+	failed := make([]string, 0)
+	dom.Find("img[src],script[src],link[href]").Each(func(_ int, s *goquery.Selection) {
+		attr := "src"
+		if s.Is("link") {
+			if rel, _ := s.Attr("rel"); rel != "stylesheet" {
+				return
+			}
+			attr = "href"
+		}
+		u, err := bow.attrToResolvedUrl(attr, s)
+		if err != nil || u.Scheme == "data" {
+			return
+		}
+		if bow.attribute(SkipExternalAssets) && u.Host != page.Host {
+			s.SetAttr(attr, u.String())
+			return
+		}
+
+		name, ok := saved[u.String()]
+		if !ok {
+			name = uniqueFileName(path.Base(u.Path), names)
+			err = bow.downloadToFile(u, filepath.Join(dir, name))
+			if err != nil {
+				failed = append(failed, err.Error())
+				s.SetAttr(attr, u.String())
+				return
+			}
+			saved[u.String()] = name
+		}
+		s.SetAttr(attr, name)
+	})
+
+	h, err := dom.Html()
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte(h), 0644)
+	if err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		return errors.New(
+			"Failed to download %d asset(s): %s", len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
 // Stylesheets returns an array of every stylesheet linked to the document.
 func (bow *Browser) Stylesheets() []*Stylesheet {
 	stylesheets := make([]*Stylesheet, 0, InitialAssetsSliceSize)
@@ -383,214 +1693,2453 @@ func (bow *Browser) Scripts() []*Script {
 	return scripts
 }
 
+// Favicon returns the URL of the page favicon.
+//
+// The URL is read from the first link tag with a rel value of "icon" or
+// "shortcut icon". The URL "/favicon.ico" at the site root is returned when
+// the page does not declare a favicon.
+//
+// Returns an error when a page has not been loaded.
+func (bow *Browser) Favicon() (*url.URL, error) {
+	if !bow.loaded() {
+		return nil, errors.NewPageNotLoaded("Cannot find the favicon.")
+	}
+
+	var icon *url.URL
+	bow.Find("link[rel][href]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		rel, _ := s.Attr("rel")
+		for _, r := range strings.Fields(strings.ToLower(rel)) {
+			if r == "icon" {
+				href, err := bow.attrToResolvedUrl("href", s)
+				if err == nil {
+					icon = href
+					return false
+				}
+			}
+		}
+		return true
+	})
+	if icon != nil {
+		return icon, nil
+	}
+
+	return bow.ResolveUrl(&url.URL{Path: "/favicon.ico"}), nil
+}
+
+// CanonicalURL returns the canonical URL declared by the page.
+//
+// The URL is read from the first link tag with a rel value of "canonical",
+// and is resolved against the page URL.
+//
+// Returns a PageNotLoaded error when a page has not been loaded, and an
+// ElementNotFound error when the page does not declare a canonical URL.
+func (bow *Browser) CanonicalURL() (*url.URL, error) {
+	if !bow.loaded() {
+		return nil, errors.NewPageNotLoaded("Cannot find the canonical URL.")
+	}
+
+	var canonical *url.URL
+	bow.Find("link[rel][href]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		rel, _ := s.Attr("rel")
+		for _, r := range strings.Fields(strings.ToLower(rel)) {
+			if r == "canonical" {
+				href, err := bow.attrToResolvedUrl("href", s)
+				if err == nil {
+					canonical = href
+					return false
+				}
+			}
+		}
+		return true
+	})
+	if canonical == nil {
+		return nil, errors.NewElementNotFound(
+			"The page does not declare a canonical URL.")
+	}
+
+	return canonical, nil
+}
+
 // SiteCookies returns the cookies for the current site.
 func (bow *Browser) SiteCookies() []*http.Cookie {
-	return bow.cookies.Cookies(bow.Url())
+	u := bow.Url()
+	cj := bow.CookieJar()
+	if u == nil || cj == nil {
+		return nil
+	}
+	return cj.Cookies(u)
+}
+
+// CookiesForURL returns the cookies the browser would send to the given URL.
+//
+// Relative URLs are resolved against the page URL. Returns nil when the URL
+// cannot be parsed or cookies are disabled.
+func (bow *Browser) CookiesForURL(u string) []*http.Cookie {
+	ur, err := url.Parse(u)
+	cj := bow.CookieJar()
+	if err != nil || cj == nil {
+		return nil
+	}
+	return cj.Cookies(bow.ResolveUrl(ur))
+}
+
+// ExportCookies returns every cookie in the cookie jar with all of its attributes.
+//
+// Returns nil when the cookie jar does not implement jar.CookiesJar.
+func (bow *Browser) ExportCookies() []*http.Cookie {
+	if cj, ok := bow.CookieJar().(jar.CookiesJar); ok {
+		return cj.ExportCookies()
+	}
+	return nil
 }
 
 // SetCookieJar is used to set the cookie jar the browser uses.
+//
+// Setting a nil jar, including a nil pointer such as a nil *cookiejar.Jar,
+// disables cookies like DisableCookies() does. Requests are then made without
+// cookies, and SiteCookies(), CookiesForURL() and ExportCookies() return nil.
 func (bow *Browser) SetCookieJar(cj http.CookieJar) {
-	bow.cookies = cj
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	bow.cookies = cookieJar(cj)
 }
 
-// SetUserAgent sets the user agent.
-func (bow *Browser) SetUserAgent(userAgent string) {
-	bow.userAgent = userAgent
+// CookieJar returns the cookie jar the browser uses.
+func (bow *Browser) CookieJar() http.CookieJar {
+	bow.mu.RLock()
+	defer bow.mu.RUnlock()
+	return bow.cookies
 }
 
-// SetAttribute sets a browser instruction attribute.
+// DisableCookies stops the browser from storing and sending cookies.
+//
+// The cookie jar is removed, and the cookies it contains are discarded.
+func (bow *Browser) DisableCookies() {
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	bow.cookies = nil
+}
+
+// EnableCookies starts storing and sending cookies after DisableCookies() was called.
+//
+// The browser is given a new, empty memory cookie jar. Nothing is changed when
+// the browser already has a cookie jar.
+func (bow *Browser) EnableCookies() {
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	if bow.cookies == nil {
+		bow.cookies = jar.NewMemoryCookies()
+	}
+}
+
+// SetUserAgent sets the user agent.
+func (bow *Browser) SetUserAgent(userAgent string) {
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	bow.userAgent = userAgent
+}
+
+// SetUserAgentPool sets a pool of user agents to choose from for each request.
+//
+// The pool overrides the user agent set with SetUserAgent(). Each request uses
+// the next user agent in the pool, or a random one when the RandomUserAgent
+// attribute is set. Setting an empty pool reverts to the single user agent.
+// The browser keeps a copy of the slice, so changing it afterwards does not
+// change the pool.
+func (bow *Browser) SetUserAgentPool(agents []string) {
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	bow.userAgents = append([]string(nil), agents...)
+	bow.userAgentIndex = 0
+}
+
+// SetAttribute sets a browser instruction attribute.
 func (bow *Browser) SetAttribute(a Attribute, v bool) {
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	if bow.attributes == nil {
+		bow.attributes = make(AttributeMap)
+	}
 	bow.attributes[a] = v
 }
 
-// SetAttributes is used to set all the browser attributes.
-func (bow *Browser) SetAttributes(a AttributeMap) {
-	bow.attributes = a
+// SetAttributes is used to set all the browser attributes.
+func (bow *Browser) SetAttributes(a AttributeMap) {
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	bow.attributes = a
+}
+
+// SetFollowRedirects sets whether redirects are followed.
+//
+// It is the same as setting the FollowRedirects attribute with SetAttribute().
+func (bow *Browser) SetFollowRedirects(follow bool) {
+	bow.SetAttribute(FollowRedirects, follow)
+}
+
+// SetSendReferer sets whether the Referer header is sent.
+//
+// It is the same as setting the SendReferer attribute with SetAttribute().
+func (bow *Browser) SetSendReferer(send bool) {
+	bow.SetAttribute(SendReferer, send)
+}
+
+// SetMetaRefreshHandling sets whether the refresh meta tag is followed.
+//
+// It is the same as setting the MetaRefreshHandling attribute with SetAttribute().
+func (bow *Browser) SetMetaRefreshHandling(handle bool) {
+	bow.SetAttribute(MetaRefreshHandling, handle)
+}
+
+// SetRetry sets the number of times failed requests are retried.
+//
+// Requests which fail with a connection error, or receive one of the retry
+// status codes, are sent again after waiting for the backoff duration. The
+// backoff doubles after each attempt. An attempts value of 0 disables retries.
+func (bow *Browser) SetRetry(attempts int, backoff time.Duration) {
+	bow.retryAttempts = attempts
+	bow.retryBackoff = backoff
+}
+
+// SetRetryStatusCodes sets the response status codes which cause a request to be retried.
+//
+// Every 5xx status code causes a retry when the codes are nil.
+func (bow *Browser) SetRetryStatusCodes(codes []int) {
+	bow.retryStatusCodes = codes
+}
+
+// SetRateLimit sets the minimum time between requests to the same host.
+//
+// The browser waits before sending a request when the previous request to the
+// same host was made less than d ago. The limit applies to page requests and
+// to the downloads made by DownloadUrl(), such as page assets. Each host is
+// limited independently. A duration of 0 disables the limit.
+func (bow *Browser) SetRateLimit(d time.Duration) {
+	bow.rateLimit = d
+}
+
+// SetMaxResponseSize sets the maximum number of bytes read from a page response.
+//
+// Loading a page returns an error when the response body is larger than n
+// bytes, and the browser state is not changed. A size of 0 disables the limit.
+func (bow *Browser) SetMaxResponseSize(n int64) {
+	bow.maxResponseSize = n
+}
+
+// SetCache sets the cache used for conditional GET requests.
+//
+// Responses to GET requests which have an ETag or Last-Modified header are
+// stored in the cache, and requesting the URL again sends the If-None-Match and
+// If-Modified-Since headers. The cached response is used when the server
+// responds with 304 Not Modified. Responses with the "no-store" Cache-Control
+// directive are not cached. Use jar.NewMemoryCache() for an in-memory cache.
+// Setting a nil cache disables caching, which is the default.
+func (bow *Browser) SetCache(c jar.Cache) {
+	bow.cache = c
+}
+
+// SetTransport sets the transport used to send requests.
+//
+// HTTP/2 is enabled on a *http.Transport unless ForceHTTP1(true) is called, even
+// when the transport has a custom TLS config or dialer. The transport is copied
+// before being changed. Setting a nil transport uses http.DefaultTransport.
+func (bow *Browser) SetTransport(rt http.RoundTripper) {
+	bow.transport = rt
+	bow.clientTransport = bow.configureTransport()
+}
+
+// ForceHTTP1 sets whether requests are sent using HTTP/1.1 instead of HTTP/2.
+//
+// Use this when a server does not handle HTTP/2 correctly. Only a *http.Transport
+// can be changed, and other transports set with SetTransport are used as-is.
+func (bow *Browser) ForceHTTP1(force bool) {
+	bow.forceHTTP1 = force
+	bow.clientTransport = bow.configureTransport()
+}
+
+// SetMaxIdleConns sets the maximum number of idle connections kept for reuse.
+//
+// The limit is across all hosts, and passing 0 uses the limit of the
+// transport. A copy of the transport is changed, which is then used for every
+// request made by the browser and its clones so connections are reused. Only
+// a *http.Transport can be changed.
+func (bow *Browser) SetMaxIdleConns(n int) {
+	bow.maxIdleConns = n
+	bow.clientTransport = bow.configureTransport()
+}
+
+// SetMaxIdleConnsPerHost sets the maximum number of idle connections kept for
+// reuse with each host.
+//
+// Raise the limit when making many concurrent requests to the same host, since
+// http.DefaultTransport only keeps 2 idle connections per host. Passing 0 uses
+// the limit of the transport. Only a *http.Transport can be changed.
+func (bow *Browser) SetMaxIdleConnsPerHost(n int) {
+	bow.maxIdleConnsPerHost = n
+	bow.clientTransport = bow.configureTransport()
+}
+
+// SetDryRun sets whether requests are built without being sent.
+//
+// In dry-run mode requests are built and the PreRequest event and middleware
+// are run as usual, but the request is not sent. A 200 response with an empty
+// body is used instead, so opening a page loads an empty page. Use
+// DryRunRequest() to get the request which would have been sent.
+func (bow *Browser) SetDryRun(dryRun bool) {
+	bow.dryRun = dryRun
+}
+
+// DryRunRequest returns the last request built in dry-run mode.
+//
+// The request includes the cookies from the cookie jar which would have been
+// sent, and the body of the request can be read. Returns nil when no request
+// has been built in dry-run mode.
+func (bow *Browser) DryRunRequest() *http.Request {
+	bow.mu.RLock()
+	defer bow.mu.RUnlock()
+	return bow.dryRunRequest
+}
+
+// SetClientCertificate sets the certificate presented to servers which request one.
+//
+// Use this to connect to servers which require mutual TLS. The certificate is
+// added to the TLS config of the transport, including a transport set with
+// SetTransport(), and replaces any certificates already in the config. Only a
+// *http.Transport can be changed.
+func (bow *Browser) SetClientCertificate(cert tls.Certificate) {
+	bow.clientCerts = []tls.Certificate{cert}
+	bow.clientTransport = bow.configureTransport()
+}
+
+// LoadClientCertificate loads the certificate presented to servers from PEM files.
+//
+// The files are loaded with tls.LoadX509KeyPair() and passed to
+// SetClientCertificate(). The certificate is not changed when the files can't
+// be loaded.
+func (bow *Browser) LoadClientCertificate(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	bow.SetClientCertificate(cert)
+	return nil
+}
+
+// SetLogger sets the logger which receives the browser log messages.
+//
+// Use NewStdLogger() to log to a *log.Logger. Setting a nil logger disables
+// logging, which is the default.
+func (bow *Browser) SetLogger(l Logger) {
+	bow.logger = l
+}
+
+// SetLoggerWriter writes the browser log messages at or above the given level to w.
+//
+// It is a shortcut for SetLogger(NewWriterLogger(w, lev)).
+func (bow *Browser) SetLoggerWriter(w io.Writer, lev LogLevel) {
+	bow.SetLogger(NewWriterLogger(w, lev))
+}
+
+// SetDebug sets whether every browser log message is written to stderr.
+//
+// Passing false disables logging, including a logger set with SetLogger().
+func (bow *Browser) SetDebug(debug bool) {
+	if debug {
+		bow.SetLoggerWriter(os.Stderr, LevelDebug)
+	} else {
+		bow.SetLogger(nil)
+	}
+}
+
+// Use adds middleware which is called with each request before it is sent.
+//
+// Middleware is called in the order it was added, after the request has been
+// built and the PreRequest event dispatched. The first middleware to return an
+// error stops the request, and the error is returned by the browser method
+// which made the request.
+func (bow *Browser) Use(m ...Middleware) {
+	bow.middleware = append(bow.middleware, m...)
+}
+
+// SetRedirectPolicy sets the policy which decides whether a redirect is followed.
+//
+// The policy is only used when the FollowRedirects attribute is true, and is
+// called before the event.Redirect event is dispatched. Use SameDomainRedirects
+// to block redirects which leave the domain of the first request. Setting a nil
+// policy follows every redirect, which is the default.
+func (bow *Browser) SetRedirectPolicy(p RedirectPolicy) {
+	bow.redirectPolicy = p
+}
+
+// SetURLRewriter sets the function which rewrites the URL each request is sent to.
+//
+// Use this to send requests to a mirror or a local replica of a site. Only the
+// URL the request is sent to is rewritten, and the page URL, links, redirects
+// and cookies use the original URL. Setting a nil rewriter sends requests to
+// their original URL, which is the default.
+func (bow *Browser) SetURLRewriter(r URLRewriter) {
+	bow.urlRewriter = r
+}
+
+// SetDocumentParser sets the parser which creates the document of each page.
+//
+// The parser is not used for responses which are not parsed, such as the
+// responses to HEAD requests. Setting a nil parser uses
+// goquery.NewDocumentFromResponse(), which is the default.
+func (bow *Browser) SetDocumentParser(p DocumentParser) {
+	bow.parser = p
+}
+
+// SetDocumentFilter sets the filters which change the document of each page.
+//
+// The filters are called in order once the page has been parsed, before the
+// PostRequest event is dispatched, and the filtered document is the one used
+// by Find(), Links() and the other page methods. Calling SetDocumentFilter()
+// without any filters removes the current filters.
+func (bow *Browser) SetDocumentFilter(filters ...DocumentFilter) {
+	bow.filters = append([]DocumentFilter(nil), filters...)
+}
+
+// SetBookmarksJar sets the bookmarks jar the browser uses.
+func (bow *Browser) SetBookmarksJar(bj jar.BookmarksJar) {
+	bow.bookmarks = bj
+}
+
+// SetHistoryJar is used to set the history jar the browser uses.
+func (bow *Browser) SetHistoryJar(hj jar.History) {
+	bow.history = hj
+}
+
+// History returns the visited pages, starting with the oldest.
+//
+// The last state is the current page. The pages left by calling Back() are
+// not included. The states are copies, and changing them does not change the
+// history. Only the current page is returned when the history jar is not a
+// jar.HistoryLister.
+func (bow *Browser) History() []*jar.State {
+	bow.mu.RLock()
+	defer bow.mu.RUnlock()
+	all := append(bow.historyStates(), bow.state)
+	states := make([]*jar.State, 0, len(all))
+	for _, st := range all {
+		if st != nil {
+			c := *st
+			states = append(states, &c)
+		}
+	}
+	return states
+}
+
+// ClearHistory removes the previous pages from the history.
+//
+// The current page is kept, but Back() and Forward() return false until
+// another page is loaded.
+func (bow *Browser) ClearHistory() {
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	bow.clearHistory()
+	bow.forward = nil
+}
+
+// historyStates returns the states in the history, starting with the oldest.
+//
+// Only a jar.HistoryLister can list its states, so the history is treated as
+// empty when it does not implement the interface. The caller must hold mu.
+func (bow *Browser) historyStates() []*jar.State {
+	if his, ok := bow.history.(jar.HistoryLister); ok {
+		return his.All()
+	}
+	return nil
+}
+
+// clearHistory removes every state from the history.
+//
+// A history which is not a jar.HistoryClearer is emptied by popping each
+// state. The caller must hold mu.
+func (bow *Browser) clearHistory() {
+	if bow.history == nil {
+		return
+	}
+	if his, ok := bow.history.(jar.HistoryClearer); ok {
+		his.Clear()
+		return
+	}
+	for n := bow.history.Len(); n > 0; n-- {
+		bow.history.Pop()
+	}
+}
+
+// Reset returns the browser to a fresh session.
+//
+// The cookies, history, current page, request headers, host headers, Referer
+// override, dry-run request and stats are cleared, and any pending meta
+// refresh is stopped. The cookie jar is replaced with a new memory jar unless
+// cookies are disabled. Configuration such as the user agents, attributes,
+// middleware, retry, rate limit and cache settings is kept.
+func (bow *Browser) Reset() {
+	bow.requestMu.Lock()
+	defer bow.requestMu.Unlock()
+	bow.StopMetaRefresh()
+
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	if bow.cookies != nil {
+		bow.cookies = jar.NewMemoryCookies()
+	}
+	bow.clearHistory()
+	bow.state = nil
+	bow.forward = nil
+	bow.headers = jar.NewMemoryHeaders()
+	bow.hostHeaders = nil
+	bow.referer = ""
+	bow.dryRunRequest = nil
+	bow.bytesRead = 0
+	bow.requests = 0
+	bow.errors = 0
+	bow.redirects = 0
+}
+
+// SetHeadersJar sets the headers the browser sends with each request.
+func (bow *Browser) SetHeadersJar(h http.Header) {
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	bow.headers = h
+}
+
+// AddRequestHeader sets a header the browser sends with each request.
+func (bow *Browser) AddRequestHeader(name, value string) {
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	bow.headers.Add(name, value)
+}
+
+// SetStripParams sets the query parameters removed from the URLs the browser uses.
+//
+// The parameters are removed from the URL of each request, and from the URLs
+// returned by ResolveUrl(), so Links(), Images() and the other page assets are
+// also cleaned. A trailing "*" matches any parameter with the prefix, eg
+// "utm_*". Use DefaultStripParams to remove common tracking parameters:
+//
+//	bow.SetStripParams(browser.DefaultStripParams)
+//
+// Setting nil keeps every parameter, which is the default.
+func (bow *Browser) SetStripParams(params []string) {
+	bow.stripParams = append([]string(nil), params...)
+}
+
+// SetAccept sets the Accept header sent with each request.
+//
+// The media types replace any Accept header already set, and are sent in the
+// given order, eg SetAccept("application/json", "text/plain;q=0.5"). Calling
+// SetAccept without any media types removes the Accept header.
+func (bow *Browser) SetAccept(mediaTypes ...string) {
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	if bow.headers == nil {
+		bow.headers = make(http.Header)
+	}
+	if len(mediaTypes) == 0 {
+		bow.headers.Del("Accept")
+		return
+	}
+	bow.headers.Set("Accept", strings.Join(mediaTypes, ", "))
+}
+
+// AcceptHTML sets the Accept header to prefer HTML documents.
+//
+// The header is the same as the one sent by web browsers.
+func (bow *Browser) AcceptHTML() {
+	bow.SetAccept("text/html", "application/xhtml+xml", "application/xml;q=0.9", "*/*;q=0.8")
+}
+
+// AcceptJSON sets the Accept header to accept JSON documents.
+func (bow *Browser) AcceptJSON() {
+	bow.SetAccept("application/json")
+}
+
+// AcceptXML sets the Accept header to accept XML documents.
+func (bow *Browser) AcceptXML() {
+	bow.SetAccept("application/xml", "text/xml;q=0.9")
+}
+
+// SetHostHeaders sets the headers the browser sends with requests to the given host.
+//
+// The host may include a port, eg "example.com:8080", in which case the headers
+// are only sent to that port. The host headers replace the headers set with
+// SetHeadersJar() and AddRequestHeader() which have the same name. Passing
+// nil or empty headers removes the headers for the host.
+func (bow *Browser) SetHostHeaders(host string, h http.Header) {
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	host = strings.ToLower(host)
+	if len(h) == 0 {
+		delete(bow.hostHeaders, host)
+		return
+	}
+	if bow.hostHeaders == nil {
+		bow.hostHeaders = make(map[string]http.Header)
+	}
+	bow.hostHeaders[host] = copyHeaders(h)
+}
+
+// SetBasicAuthForHost sets the basic auth credentials sent with requests to the given host.
+//
+// The credentials are sent in an Authorization header which is added to the
+// headers for the host, like SetHostHeaders() does, so they are not sent to
+// other hosts, including when a redirect leaves the host. An Authorization
+// header added with AddRequestHeader() is still sent to the other hosts.
+// Passing an empty user and password removes the credentials for the host.
+func (bow *Browser) SetBasicAuthForHost(host, user, pass string) {
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	host = strings.ToLower(host)
+	h := copyHeaders(bow.hostHeaders[host])
+	if user == "" && pass == "" {
+		h.Del("Authorization")
+	} else {
+		h.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+pass)))
+	}
+	if len(h) == 0 {
+		delete(bow.hostHeaders, host)
+		return
+	}
+	if bow.hostHeaders == nil {
+		bow.hostHeaders = make(map[string]http.Header)
+	}
+	bow.hostHeaders[host] = h
+}
+
+// SetReferer sets the Referer header sent with each request.
+//
+// The given URL is sent instead of the URL of the previous page, including
+// requests which would not otherwise send a Referer header. The header is only
+// sent when the SendReferer attribute is true. Setting an empty string restores
+// the default behavior.
+func (bow *Browser) SetReferer(u string) {
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	bow.referer = u
+}
+
+// ResolveUrl returns an absolute URL for a possibly relative URL.
+//
+// Relative URLs are resolved against the href of the base tag of the page
+// when it has one, like web browsers do, or the page URL otherwise.
+// Internationalized host names are converted to punycode, and the percent
+// encoding of the path is preserved.
+func (bow *Browser) ResolveUrl(u *url.URL) *url.URL {
+	base := bow.baseUrl()
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if a, err := asciiHost(u); err == nil {
+		u = a
+	}
+	return bow.stripQuery(u)
+}
+
+// baseUrl returns the URL relative URLs in the page are resolved against.
+//
+// The href of the first base tag in the page is used when present, after
+// being resolved against the page URL. Otherwise the page URL is used.
+func (bow *Browser) baseUrl() *url.URL {
+	page := bow.Url()
+	if page == nil {
+		return nil
+	}
+	href, ok := bow.Find("base[href]").First().Attr("href")
+	if !ok {
+		return page
+	}
+	base, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return page
+	}
+	return page.ResolveReference(base)
+}
+
+// ResolveStringUrl works just like ResolveUrl, but the argument and return value are strings.
+func (bow *Browser) ResolveStringUrl(u string) (string, error) {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return "", err
+	}
+	return bow.ResolveUrl(pu).String(), nil
+}
+
+// Download writes the contents of the document to the given writer.
+func (bow *Browser) Download(o io.Writer) (int64, error) {
+	st := bow.currentState()
+	if st == nil {
+		return 0, errors.NewPageNotLoaded("Cannot download, no page has been loaded.")
+	}
+	if st.Raw != nil {
+		l, err := o.Write(st.Raw)
+		return int64(l), err
+	}
+	h, err := st.Dom.Html()
+	if err != nil {
+		return 0, err
+	}
+	l, err := io.WriteString(o, h)
+	return int64(l), err
+}
+
+// DownloadUrl writes the contents of the given URL to the given writer.
+//
+// The URL is resolved against the page URL, and the request is made using the
+// browser cookies and headers. The request is retried the same way as a page
+// request when SetRetry() has been used. The browser state is not changed.
+func (bow *Browser) DownloadUrl(u *url.URL, o io.Writer) (int64, error) {
+	u = bow.ResolveUrl(u)
+	req, err := bow.buildRequest(fetchRequest, "GET", u.String(), bow.Url(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req, err = bow.prepareRequest(req)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := bow.sendRequest(req, RequestOptions{})
+	if err != nil {
+		return 0, bow.requestError(req, err)
+	}
+	bow.dispatchCookies(resp)
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return 0, bow.requestError(req, errors.NewPageNotFound(
+			"Received status %d for '%s'.", resp.StatusCode, u.String()))
+	}
+
+	n, err := io.Copy(o, resp.Body)
+	bow.addBytesRead(n)
+	if err != nil {
+		return n, bow.requestError(req, err)
+	}
+	return n, nil
+}
+
+// Url returns the page URL as a string.
+//
+// The URL is the final URL after any redirects were followed. Returns nil when
+// no page has been loaded.
+func (bow *Browser) Url() *url.URL {
+	st := bow.currentState()
+	if st == nil {
+		return nil
+	}
+	return st.Request.URL
+}
+
+// RequestedURL returns the URL which was requested to load the page.
+//
+// The URL is the same as Url() unless redirects were followed. Returns nil
+// when no page has been loaded.
+func (bow *Browser) RequestedURL() *url.URL {
+	st := bow.currentState()
+	if st == nil {
+		return nil
+	}
+	if st.RequestedURL == nil {
+		return st.Request.URL
+	}
+	return st.RequestedURL
+}
+
+// WasRedirected returns whether redirects were followed to load the page.
+//
+// It is true when the page URL is not the URL which was requested, see
+// RequestedURL(). Returns false when no page has been loaded.
+func (bow *Browser) WasRedirected() bool {
+	st := bow.currentState()
+	if st == nil || st.Request == nil || st.RequestedURL == nil {
+		return false
+	}
+	return st.RequestedURL.String() != st.Request.URL.String()
+}
+
+// StatusCode returns the response status code.
+//
+// Returns 0 when no page has been loaded.
+func (bow *Browser) StatusCode() int {
+	st := bow.currentState()
+	if st == nil {
+		return 0
+	}
+	return st.Response.StatusCode
+}
+
+// Title returns the page title.
+//
+// Returns an empty string when the page does not have an HTML, XML or text
+// content type, eg a JSON response or an image.
+func (bow *Browser) Title() string {
+	st := bow.currentState()
+	if st == nil || !bow.markup(st, "title") {
+		return ""
+	}
+	return st.Dom.Find("title").Text()
+}
+
+// Meta returns the content of the meta tag with the given name, eg
+// Meta("description") for <meta name="description" content="...">.
+//
+// Names are matched case-insensitively. Returns an empty string when the page
+// does not have the meta tag, or no page has been loaded.
+func (bow *Browser) Meta(name string) string {
+	return bow.metaContent("name", name)
+}
+
+// MetaProperty returns the content of the meta tag with the given property,
+// eg MetaProperty("og:title") for <meta property="og:title" content="...">.
+//
+// Properties are matched case-insensitively. Returns an empty string when the
+// page does not have the meta tag, or no page has been loaded.
+func (bow *Browser) MetaProperty(property string) string {
+	return bow.metaContent("property", property)
+}
+
+// metaContent returns the content of the first meta tag with the given
+// attribute set to the given value.
+func (bow *Browser) metaContent(attr, value string) string {
+	content := ""
+	bow.Find("meta[" + attr + "][content]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if strings.EqualFold(strings.TrimSpace(s.AttrOr(attr, "")), value) {
+			content = s.AttrOr("content", "")
+			return false
+		}
+		return true
+	})
+	return content
+}
+
+// ResponseHeaders returns the page headers.
+func (bow *Browser) ResponseHeaders() http.Header {
+	st := bow.currentState()
+	if st == nil {
+		return http.Header{}
+	}
+	return st.Response.Header
+}
+
+// State returns the current browser state.
+//
+// The state holds the request, response and document for the page, and can be
+// passed to SetState() to return to the page later without requesting it again.
+// Returns nil when no page has been loaded.
+func (bow *Browser) State() *jar.State {
+	return bow.currentState()
+}
+
+// SetState restores the browser state returned by State().
+//
+// The page accessors, eg Url(), Title() and StatusCode(), read from the
+// restored state. The history is not changed, and any pending meta refresh of
+// the current page is stopped. The body of the restored response has already
+// been read and can't be read again.
+func (bow *Browser) SetState(s *jar.State) {
+	bow.StopMetaRefresh()
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	bow.state = s
+}
+
+// Response returns the *http.Response for the page.
+//
+// The response body has already been read when the page was loaded, and can't
+// be read again. Use Body() or Download() to get the page content.
+func (bow *Browser) Response() *http.Response {
+	st := bow.currentState()
+	if st == nil {
+		return nil
+	}
+	return st.Response
+}
+
+// Protocol returns the protocol of the page response, eg "HTTP/2.0".
+//
+// Returns an empty string when no page has been loaded.
+func (bow *Browser) Protocol() string {
+	st := bow.currentState()
+	if st == nil || st.Response == nil {
+		return ""
+	}
+	return st.Response.Proto
+}
+
+// Language returns the language of the page as a BCP 47 tag, eg "en-US".
+//
+// The language is read from the lang attribute of the html element, or the
+// Content-Language header when the attribute is missing. Only the first
+// language in the header is used. Returns an empty string when the page does
+// not declare a language.
+func (bow *Browser) Language() string {
+	lang, ok := bow.Find("html").Attr("lang")
+	if !ok || strings.TrimSpace(lang) == "" {
+		lang = strings.Split(bow.ResponseHeaders().Get("Content-Language"), ",")[0]
+	}
+	return normalizeLanguage(lang)
+}
+
+// AsCurl returns the request for the page as a curl command.
+//
+// The command includes the request method, URL, headers and body. The
+// Authorization and Proxy-Authorization headers, and the cookies sent with the
+// request, are only included when the CurlSensitiveHeaders attribute is true.
+// Returns an empty string when no page has been loaded.
+func (bow *Browser) AsCurl() string {
+	st := bow.currentState()
+	if st == nil || st.Request == nil {
+		return ""
+	}
+	req := st.Request
+	sensitive := bow.attribute(CurlSensitiveHeaders)
+
+	cmd := []string{"curl", "-X", shellQuote(req.Method), shellQuote(req.URL.String())}
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !sensitive && isSensitiveHeader(name) {
+			continue
+		}
+		for _, v := range req.Header[name] {
+			cmd = append(cmd, "-H", shellQuote(name+": "+v))
+		}
+	}
+	if cj := bow.CookieJar(); sensitive && cj != nil {
+		cookies := cj.Cookies(req.URL)
+		if len(cookies) > 0 {
+			pairs := make([]string, len(cookies))
+			for i, c := range cookies {
+				pairs[i] = c.Name + "=" + c.Value
+			}
+			cmd = append(cmd, "-b", shellQuote(strings.Join(pairs, "; ")))
+		}
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			b, err := ioutil.ReadAll(body)
+			body.Close()
+			if err == nil && len(b) > 0 {
+				cmd = append(cmd, "--data-binary", shellQuote(string(b)))
+			}
+		}
+	}
+	return strings.Join(cmd, " ")
+}
+
+// ContentType returns the media type of the page response.
+//
+// The media type is read from the Content-Type header without any parameters,
+// eg "text/html". Returns an empty string when the header is missing or can't
+// be parsed.
+func (bow *Browser) ContentType() string {
+	mt, _, err := mime.ParseMediaType(bow.ResponseHeaders().Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return mt
+}
+
+// IsHTML returns whether the page response is an HTML document.
+func (bow *Browser) IsHTML() bool {
+	ct := bow.ContentType()
+	return ct == "text/html" || ct == "application/xhtml+xml"
+}
+
+// IsJSON returns whether the page response is a JSON document.
+func (bow *Browser) IsJSON() bool {
+	ct := bow.ContentType()
+	return ct == "application/json" || strings.HasSuffix(ct, "+json")
+}
+
+// AllowedMethods returns the methods listed in the Allow header of the page response.
+//
+// Returns nil when the response does not have an Allow header.
+func (bow *Browser) AllowedMethods() []string {
+	allow := bow.ResponseHeaders().Get("Allow")
+	if allow == "" {
+		return nil
+	}
+	methods := make([]string, 0, 8)
+	for _, m := range strings.Split(allow, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			methods = append(methods, strings.ToUpper(m))
+		}
+	}
+	return methods
+}
+
+// Body returns the page body as a string of html.
+//
+// Returns an empty string when the page does not have an HTML, XML or text
+// content type, eg a JSON response or an image. Use ResponseBytes() to read
+// the body of those pages.
+func (bow *Browser) Body() string {
+	st := bow.currentState()
+	if st == nil || !bow.markup(st, "body") {
+		return ""
+	}
+	if st.Raw != nil {
+		return string(st.Raw)
+	}
+	body, _ := st.Dom.Find("body").Html()
+	return body
+}
+
+// Text returns the visible text of the page.
+//
+// The text of the script, style, noscript and template elements is not
+// included, and each run of whitespace is replaced with a single space.
+// Returns an empty string when the page was not parsed as HTML.
+func (bow *Browser) Text() string {
+	body := bow.Find("body").Clone()
+	body.Find("script,style,noscript,template").Remove()
+	return strings.Join(strings.Fields(body.Text()), " ")
+}
+
+// RawBody returns the body of a page which was not parsed as HTML.
+//
+// The body of a page is only kept when the ParseHTMLOnly attribute is true and
+// the page does not have an HTML or XML content type. The document of the page
+// is empty instead. Returns nil when the page was parsed.
+func (bow *Browser) RawBody() []byte {
+	st := bow.currentState()
+	if st == nil {
+		return nil
+	}
+	return st.Raw
+}
+
+// ResponseBytes returns the body of the page as bytes.
+//
+// The body is returned as it was received for pages which do not have an HTML
+// or XML content type, eg a JSON response. The document is rendered as HTML
+// for other pages. Returns nil when no page has been loaded.
+func (bow *Browser) ResponseBytes() []byte {
+	st := bow.currentState()
+	if st == nil {
+		return nil
+	}
+	if st.Raw != nil {
+		return st.Raw
+	}
+	if st.Content != nil {
+		return st.Content
+	}
+	html, _ := goquery.OuterHtml(st.Dom.Selection)
+	return []byte(html)
+}
+
+// BytesRead returns the number of bytes read from the body of the page.
+//
+// The size is of the body after it has been decompressed. A page served from
+// the cache after a 304 Not Modified response has a size of 0. Returns 0 when
+// no page has been loaded.
+func (bow *Browser) BytesRead() int64 {
+	st := bow.currentState()
+	if st == nil {
+		return 0
+	}
+	return st.BytesRead
+}
+
+// TotalBytesRead returns the number of bytes read from response bodies by the browser.
+//
+// The total includes every page loaded, every file downloaded with
+// DownloadUrl() and every body read from Stream() by the browser, but not by
+// its clones.
+func (bow *Browser) TotalBytesRead() int64 {
+	bow.mu.RLock()
+	defer bow.mu.RUnlock()
+	return bow.bytesRead
+}
+
+// Stats returns the counters of the requests made by the browser.
+//
+// Every attempt to send a request is counted, including retries, downloads and
+// the requests made by CheckLinks(), but not requests in dry-run mode. Each
+// request which fails is counted once as an error, including the downloads and
+// streams which received an error status. The counters are cleared by Reset(),
+// and are not shared with clones.
+func (bow *Browser) Stats() Stats {
+	bow.mu.RLock()
+	defer bow.mu.RUnlock()
+	return Stats{
+		Requests:  bow.requests,
+		BytesRead: bow.bytesRead,
+		Errors:    bow.errors,
+		Redirects: bow.redirects,
+	}
+}
+
+// FromCache returns whether the page was served from the cache.
+//
+// It is true when the server responded with 304 Not Modified and the cached
+// response set with SetCache() was used. Returns false when the page came
+// from the network, or when no page has been loaded.
+func (bow *Browser) FromCache() bool {
+	st := bow.currentState()
+	if st == nil {
+		return false
+	}
+	return st.FromCache
+}
+
+// Dom returns the inner *goquery.Selection.
+func (bow *Browser) Dom() *goquery.Selection {
+	st := bow.currentState()
+	if st == nil {
+		return &goquery.Selection{}
+	}
+	return st.Dom.First()
+}
+
+// FindText returns the text of the first element matching the given expression.
+//
+// The text is trimmed of leading and trailing whitespace. Returns an empty
+// string when no element matches the expression.
+func (bow *Browser) FindText(expr string) string {
+	return strings.TrimSpace(bow.Find(expr).First().Text())
+}
+
+// FindAttr returns an attribute of the first element matching the given expression.
+//
+// Returns false when no element matches the expression, or the element does
+// not have the attribute.
+func (bow *Browser) FindAttr(expr, attr string) (string, bool) {
+	return bow.Find(expr).First().Attr(attr)
+}
+
+// Each calls the given function with each element matching the given expression.
+//
+// It is the same as Find(expr).Each(fn). The function is not called when no
+// element matches the expression, or no page has been loaded.
+func (bow *Browser) Each(expr string, fn func(i int, s *goquery.Selection)) {
+	bow.Find(expr).Each(fn)
+}
+
+// SetDom replaces the document of the page.
+//
+// The new document is created from the first node in the selection, and is
+// used by Find(), Body(), Download() and the other methods which read the page.
+// The URL, status code and headers of the page are not changed. The selection
+// returned by Dom() can also be changed in place, eg to remove the scripts from
+// the page, without calling SetDom().
+func (bow *Browser) SetDom(sel *goquery.Selection) error {
+	if sel == nil || sel.Length() == 0 {
+		return errors.NewElementNotFound("Cannot set the document from an empty selection.")
+	}
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	if bow.state == nil {
+		return errors.NewPageNotLoaded("Cannot set the document.")
+	}
+	st := *bow.state
+	st.Dom = goquery.NewDocumentFromNode(sel.Get(0))
+	st.Raw = nil
+	st.Content = nil
+	bow.state = &st
+	return nil
+}
+
+// Find returns the dom selections matching the given expression.
+func (bow *Browser) Find(expr string) *goquery.Selection {
+	st := bow.currentState()
+	if st == nil {
+		return &goquery.Selection{}
+	}
+	return st.Dom.Find(expr)
+}
+
+// -- Unexported methods --
+
+// buildClient creates, configures, and returns a *http.Client type.
+func (bow *Browser) buildClient() *http.Client {
+	client := &http.Client{}
+	client.Jar = bow.CookieJar()
+	client.CheckRedirect = bow.shouldRedirect
+	if bow.clientTransport != nil {
+		client.Transport = bow.clientTransport
+	}
+	if bow.urlRewriter != nil {
+		client.Transport = &rewriteTransport{
+			transport: client.Transport,
+			rewrite:   bow.urlRewriter,
+		}
+	}
+	return client
+}
+
+// rewriteTransport sends requests to the URL returned by a URLRewriter.
+type rewriteTransport struct {
+	transport http.RoundTripper
+	rewrite   URLRewriter
+}
+
+// RoundTrip sends a copy of the request to the rewritten URL. The request of
+// the response is the original request.
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u := *req.URL
+	if req.URL.User != nil {
+		user := *req.URL.User
+		u.User = &user
+	}
+	ru := t.rewrite(&u)
+	if ru == nil {
+		ru = req.URL
+	}
+	out := req.Clone(req.Context())
+	out.URL = ru
+	out.Host = ""
+
+	rt := t.transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	resp, err := rt.RoundTrip(out)
+	if resp != nil {
+		resp.Request = req
+	}
+	return resp, err
+}
+
+// configureTransport returns the transport used by the client.
+func (bow *Browser) configureTransport() http.RoundTripper {
+	if bow.transport == nil && !bow.forceHTTP1 && len(bow.clientCerts) == 0 &&
+		bow.maxIdleConns == 0 && bow.maxIdleConnsPerHost == 0 {
+		return nil
+	}
+	rt := bow.transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	t, ok := rt.(*http.Transport)
+	if !ok {
+		return rt
+	}
+	t = t.Clone()
+	if bow.maxIdleConns > 0 {
+		t.MaxIdleConns = bow.maxIdleConns
+	}
+	if bow.maxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = bow.maxIdleConnsPerHost
+	}
+	if len(bow.clientCerts) > 0 {
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		t.TLSClientConfig.Certificates = bow.clientCerts
+	}
+	if bow.forceHTTP1 {
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+		if t.TLSClientConfig != nil {
+			t.TLSClientConfig.NextProtos = []string{"http/1.1"}
+		}
+	} else {
+		t.ForceAttemptHTTP2 = true
+	}
+	return t
+}
+
+// requestKind is the kind of a request, which decides the client hints sent
+// with it.
+type requestKind int
+
+const (
+	// navigateRequest loads a page, eg Open(), Click() or submitting a form.
+	navigateRequest requestKind = iota
+
+	// fetchRequest is made by the page rather than to load it, eg Exists(),
+	// Stream(), downloads, checking links and JSON requests.
+	fetchRequest
+)
+
+// buildRequest creates and returns a *http.Request type of the given kind.
+// Sets any headers that need to be sent with the request.
+func (bow *Browser) buildRequest(kind requestKind, method, url string, ref *url.URL, body io.Reader) (*http.Request, error) {
+	body, err := replayableBody(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	u, err := asciiHost(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	req.URL = bow.stripQuery(u)
+	req.Host = req.URL.Host
+	bow.mu.RLock()
+	req.Header = copyHeaders(bow.headers)
+	clientHints, sendReferer := bow.attributes[ClientHints], bow.attributes[SendReferer]
+	referer := bow.referer
+	bow.mu.RUnlock()
+	for k, v := range bow.headersForHost(req.URL) {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	ua := bow.nextUserAgent()
+	req.Header.Add("User-Agent", ua)
+	if clientHints {
+		setClientHints(req.Header, kind, ua, req.URL, ref)
+	}
+	if sendReferer {
+		if referer != "" {
+			req.Header.Set("Referer", referer)
+		} else if ref != nil {
+			req.Header.Add("Referer", ref.String())
+		}
+	}
+
+	return req, nil
+}
+
+// chromeVersion matches the major version of Chromium based user agents.
+var chromeVersion = regexp.MustCompile(`Chrome/(\d+)`)
+
+// setClientHints adds the DNT, Sec-Fetch-* and Sec-Ch-Ua headers a web browser
+// sends with a request of the given kind from the ref URL to the given URL with
+// the given user agent. Headers which are already set are not changed.
+//
+// A navigation is sent as a document requested by the user. Other requests are
+// sent the way a script fetches a resource, without the Sec-Fetch-User header.
+//
+// Only Chromium based browsers send the Sec-Ch-Ua headers, so they are added
+// when the user agent contains a Chrome version, and describe that version and
+// the platform named in the user agent. Chrome on iOS (CriOS) uses WebKit, and
+// does not send them.
+func setClientHints(h http.Header, kind requestKind, ua string, u, ref *url.URL) {
+	hints := [][2]string{
+		{"DNT", "1"},
+	}
+	if kind == navigateRequest {
+		hints = append(hints,
+			[2]string{"Sec-Fetch-Dest", "document"},
+			[2]string{"Sec-Fetch-Mode", "navigate"},
+			[2]string{"Sec-Fetch-Site", fetchSite(u, ref)},
+			[2]string{"Sec-Fetch-User", "?1"},
+		)
+	} else {
+		hints = append(hints,
+			[2]string{"Sec-Fetch-Dest", "empty"},
+			[2]string{"Sec-Fetch-Mode", "cors"},
+			[2]string{"Sec-Fetch-Site", fetchSite(u, ref)},
+		)
+	}
+	if m := chromeVersion.FindStringSubmatch(ua); m != nil && !strings.Contains(ua, "Firefox/") && !strings.Contains(ua, "CriOS/") {
+		brand := "Google Chrome"
+		if strings.Contains(ua, "Edg/") {
+			brand = "Microsoft Edge"
+		} else if strings.Contains(ua, "OPR/") {
+			brand = "Opera"
+		}
+		mobile := "?0"
+		if strings.Contains(ua, "Mobile") {
+			mobile = "?1"
+		}
+		hints = append(hints,
+			[2]string{"Sec-Ch-Ua", fmt.Sprintf(`"Chromium";v="%s", "%s";v="%s", "Not A(Brand";v="99"`, m[1], brand, m[1])},
+			[2]string{"Sec-Ch-Ua-Mobile", mobile},
+			[2]string{"Sec-Ch-Ua-Platform", fmt.Sprintf("%q", uaPlatform(ua))},
+		)
+	}
+	for _, hint := range hints {
+		if h.Get(hint[0]) == "" {
+			h.Set(hint[0], hint[1])
+		}
+	}
+}
+
+// fetchSite returns the value of the Sec-Fetch-Site header for a request from
+// the ref URL to the given URL.
+//
+// URLs with the same scheme and registrable domain, eg "www.example.com" and
+// "api.example.com", are the same site.
+func fetchSite(u, ref *url.URL) string {
+	switch {
+	case ref == nil:
+		return "none"
+	case ref.Scheme != u.Scheme:
+		return "cross-site"
+	case ref.Host == u.Host:
+		return "same-origin"
+	case registrableDomain(ref) == registrableDomain(u):
+		return "same-site"
+	}
+	return "cross-site"
+}
+
+// registrableDomain returns the registrable domain of the URL host, eg
+// "example.co.uk" for "www.example.co.uk". IP addresses, and hosts without a
+// registrable domain, are returned unchanged.
+func registrableDomain(u *url.URL) string {
+	host := strings.ToLower(u.Hostname())
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+// uaPlatform returns the name of the platform in the given user agent, as
+// used by the Sec-Ch-Ua-Platform header.
+func uaPlatform(ua string) string {
+	switch {
+	case strings.Contains(ua, "Android"):
+		return "Android"
+	case strings.Contains(ua, "CrOS"):
+		return "Chrome OS"
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"):
+		return "iOS"
+	case strings.Contains(ua, "Windows"):
+		return "Windows"
+	case strings.Contains(ua, "Mac OS X"), strings.Contains(ua, "Macintosh"):
+		return "macOS"
+	case strings.Contains(ua, "Linux"):
+		return "Linux"
+	}
+	return "Unknown"
+}
+
+// asciiHost returns the given URL with an internationalized host name
+// converted to punycode, eg "bücher.example" becomes "xn--bcher-kva.example".
+// The URL is returned unchanged when the host is already ASCII, otherwise a
+// copy is returned. An error is returned when the host name is not valid.
+func asciiHost(u *url.URL) (*url.URL, error) {
+	host := u.Hostname()
+	ascii := true
+	for i := 0; i < len(host); i++ {
+		if host[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return u, nil
+	}
+	h, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return nil, errors.New("Invalid host name '%s': %s", host, err)
+	}
+	c := *u
+	c.Host = h
+	if port := u.Port(); port != "" {
+		c.Host += ":" + port
+	}
+	return &c, nil
+}
+
+// stripQuery returns the given URL without the query parameters set with
+// SetStripParams(). The URL is returned unchanged when no parameters match,
+// otherwise a copy is returned.
+func (bow *Browser) stripQuery(u *url.URL) *url.URL {
+	if len(bow.stripParams) == 0 || u.RawQuery == "" {
+		return u
+	}
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return u
+	}
+	stripped := false
+	for name := range query {
+		for _, p := range bow.stripParams {
+			if name == p || (strings.HasSuffix(p, "*") && strings.HasPrefix(name, p[:len(p)-1])) {
+				delete(query, name)
+				stripped = true
+				break
+			}
+		}
+	}
+	if !stripped {
+		return u
+	}
+	c := *u
+	c.RawQuery = query.Encode()
+	return &c
+}
+
+// headersForHost returns the headers set with SetHostHeaders() for the host
+// of the given URL, preferring the headers set for the host and port.
+func (bow *Browser) headersForHost(u *url.URL) http.Header {
+	bow.mu.RLock()
+	defer bow.mu.RUnlock()
+	if h, ok := bow.hostHeaders[strings.ToLower(u.Host)]; ok {
+		return h
+	}
+	return bow.hostHeaders[strings.ToLower(u.Hostname())]
+}
+
+// attribute returns the value of the given attribute.
+func (bow *Browser) attribute(a Attribute) bool {
+	bow.mu.RLock()
+	defer bow.mu.RUnlock()
+	return bow.attributes[a]
+}
+
+// requestHeaders returns a copy of the headers sent with each request.
+func (bow *Browser) requestHeaders() http.Header {
+	bow.mu.RLock()
+	defer bow.mu.RUnlock()
+	return copyHeaders(bow.headers)
+}
+
+// nextUserAgent returns the user agent to send with the next request.
+func (bow *Browser) nextUserAgent() string {
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	if len(bow.userAgents) == 0 {
+		return bow.userAgent
+	}
+	if bow.attributes[RandomUserAgent] {
+		return bow.userAgents[rand.Intn(len(bow.userAgents))]
+	}
+	ua := bow.userAgents[bow.userAgentIndex%len(bow.userAgents)]
+	bow.userAgentIndex++
+	return ua
+}
+
+// currentState returns the page state, or nil when no page has been loaded.
+func (bow *Browser) currentState() *jar.State {
+	bow.mu.RLock()
+	defer bow.mu.RUnlock()
+	return bow.state
+}
+
+// markup returns whether the page has an HTML, XML or other text content
+// type. A debug message naming the given method is logged when it does not.
+func (bow *Browser) markup(st *jar.State, method string) bool {
+	if st.Response == nil {
+		return true
+	}
+	ct := st.Response.Header.Get("Content-Type")
+	if isMarkup(ct) || isText(ct) {
+		return true
+	}
+	bow.logDebug("Page is not HTML", "method", method, "content_type", ct)
+	return false
+}
+
+// parsed returns whether the page body was parsed into a document.
+func (bow *Browser) parsed() bool {
+	st := bow.currentState()
+	return st == nil || st.Raw == nil
+}
+
+// loaded returns whether a page has been loaded.
+func (bow *Browser) loaded() bool {
+	st := bow.currentState()
+	return st != nil && st.Request != nil
+}
+
+// downloadToDir downloads the given URL to a file in the given directory.
+// The file is named after the last element of the URL path.
+func (bow *Browser) downloadToDir(u *url.URL, dir string) error {
+	name := path.Base(u.Path)
+	if name == "." || name == "/" {
+		return errors.New(
+			"Cannot create a file name from '%s'.", u.String())
+	}
+	return bow.downloadToFile(u, filepath.Join(dir, name))
+}
+
+// downloadToFile downloads the given URL to the given file.
+// The file is removed when the download fails.
+func (bow *Browser) downloadToFile(u *url.URL, file string) (err error) {
+	fout, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := fout.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(fout.Name())
+		}
+	}()
+	_, err = bow.DownloadUrl(u, fout)
+
+	return err
+}
+
+// httpGET makes an HTTP GET request for the given URL.
+// When via is not nil, and AttributeSendReferer is true, the Referer header will
+// be set to ref.
+func (bow *Browser) httpGET(u *url.URL, ref *url.URL) error {
+	req, err := bow.buildRequest(navigateRequest, "GET", u.String(), ref, nil)
+	if err != nil {
+		return err
+	}
+	return bow.httpRequest(req)
+}
+
+// httpPOST makes an HTTP POST request for the given URL.
+// When via is not nil, and AttributeSendReferer is true, the Referer header will
+// be set to ref.
+func (bow *Browser) httpPOST(u *url.URL, ref *url.URL, contentType string, body io.Reader) error {
+	return bow.httpSend(navigateRequest, "POST", u, ref, bow.postContentType(contentType), body)
+}
+
+// httpSend makes an HTTP request of the given kind with a body for the given URL
+// using the given method. When via is not nil, and AttributeSendReferer is
+// true, the Referer header will be set to ref.
+func (bow *Browser) httpSend(kind requestKind, method string, u *url.URL, ref *url.URL, contentType string, body io.Reader) error {
+	req, err := bow.buildRequest(kind, method, u.String(), ref, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	return bow.httpRequest(req)
+}
+
+// postContentType returns the given content type, or the default content type
+// when it is empty.
+func (bow *Browser) postContentType(ct string) string {
+	if ct != "" {
+		return ct
+	}
+	if bow.contentType != "" {
+		return bow.contentType
+	}
+	return DefaultContentType
+}
+
+// httpJSON makes an HTTP request for the given URL using the given method, with
+// the given value encoded as JSON.
+func (bow *Browser) httpJSON(method string, u string, v interface{}) error {
+	ur, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return bow.httpSend(fetchRequest, method, ur, nil, "application/json", bytes.NewReader(b))
+}
+
+// send uses the given *http.Request to make an HTTP request.
+func (bow *Browser) httpRequest(req *http.Request) error {
+	return bow.httpRequestWithOptions(req, RequestOptions{})
+}
+
+// httpRequestWithOptions uses the given *http.Request and options to make an
+// HTTP request.
+func (bow *Browser) httpRequestWithOptions(req *http.Request, opts RequestOptions) error {
+	req, resp, err := bow.loadPage(req, opts)
+	if err != nil {
+		return err
+	}
+	return bow.Do(event.PostRequest, req, resp)
+}
+
+// loadPage sends the request and makes the response the current page.
+//
+// Only one page is loaded at a time. The event.PostRequest event is dispatched
+// by the caller once the lock is released, so its handlers may make requests.
+// The request which was sent is returned, which is not the given request when
+// an event.PreRequest handler replaced it.
+func (bow *Browser) loadPage(req *http.Request, opts RequestOptions) (*http.Request, *http.Response, error) {
+	bow.requestMu.Lock()
+	defer bow.requestMu.Unlock()
+
+	bow.preSend()
+	req, err := bow.prepareRequest(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	bow.prepareCache(req)
+	start := time.Now()
+	resp, err := bow.sendRequest(req, opts)
+	if err != nil {
+		return nil, nil, bow.requestError(req, err)
+	}
+	bow.dispatchCookies(resp)
+	counter := &countingReader{ReadCloser: resp.Body}
+	resp.Body = counter
+	resp, cached, err := bow.cacheResponse(req, resp)
+	if err != nil {
+		bow.addBytesRead(counter.n)
+		return nil, nil, bow.requestError(req, err)
+	}
+	bow.logInfo("Request complete",
+		"method", req.Method,
+		"url", req.URL.String(),
+		"status", resp.StatusCode,
+		"duration", time.Since(start))
+	dom, raw, content, err := parseResponse(req, resp, bow.maxResponseSize, bow.attribute(ParseHTMLOnly), bow.parser)
+	bow.addBytesRead(counter.n)
+	if err != nil {
+		return nil, nil, bow.requestError(req, err)
+	}
+	for _, filter := range bow.filters {
+		if filter != nil {
+			filter(dom)
+		}
+	}
+	final := req
+	if resp.Request != nil {
+		final = resp.Request
+	}
+	st := jar.NewHistoryState(final, resp, dom)
+	st.RequestedURL = req.URL
+	st.Raw = raw
+	st.Content = content
+	st.BytesRead = counter.n
+	st.FromCache = cached
+	st.Time = start
+	st.Duration = time.Since(start)
+	bow.mu.Lock()
+	bow.history.Push(bow.state)
+	bow.state = st
+	bow.forward = nil
+	bow.mu.Unlock()
+	bow.postSend()
+
+	return req, resp, nil
+}
+
+// parseResponse creates a document from the response body.
+//
+// The body of a response to a HEAD or OPTIONS request is not parsed, and an
+// empty document is returned instead. An error is returned when maxSize is
+// greater than 0 and the body is larger than maxSize bytes.
+//
+// When the response does not have an HTML or XML content type, the body is
+// also returned as bytes. When htmlOnly is true the body is returned as raw
+// bytes with an empty document, otherwise it is returned as the content of a
+// parsed document. The document is created by the given parser, or by goquery
+// when it is nil.
+func parseResponse(req *http.Request, resp *http.Response, maxSize int64, htmlOnly bool, parser DocumentParser) (*goquery.Document, []byte, []byte, error) {
+	if req.Method == "HEAD" || req.Method == "OPTIONS" {
+		resp.Body.Close()
+		dom, err := goquery.NewDocumentFromReader(strings.NewReader(""))
+		return dom, nil, nil, err
+	}
+	if maxSize > 0 {
+		b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if int64(len(b)) > maxSize {
+			return nil, nil, nil, errors.New(
+				"Response from '%s' is larger than the maximum size of %d bytes.",
+				req.URL.String(), maxSize)
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+	var content []byte
+	if !isMarkup(resp.Header.Get("Content-Type")) {
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if b == nil {
+			b = []byte{}
+		}
+		if htmlOnly {
+			return &goquery.Document{Selection: &goquery.Selection{}}, b, nil, nil
+		}
+		content = b
+		resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+	if parser != nil {
+		defer resp.Body.Close()
+		dom, err := parser(resp)
+		if err == nil && dom == nil {
+			err = errors.New("The document parser returned a nil document.")
+		}
+		return dom, nil, content, err
+	}
+	dom, err := goquery.NewDocumentFromResponse(resp)
+	return dom, nil, content, err
+}
+
+// isMarkup returns whether the given content type is HTML or XML. A missing
+// content type is treated as HTML.
+func isMarkup(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mt == "text/html" || mt == "application/xhtml+xml" ||
+		mt == "text/xml" || mt == "application/xml" || strings.HasSuffix(mt, "+xml")
+}
+
+// isText returns whether the given content type is a text type, such as
+// "text/plain", which is parsed like HTML.
+func isText(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	return err == nil && strings.HasPrefix(mt, "text/")
 }
 
-// SetBookmarksJar sets the bookmarks jar the browser uses.
-func (bow *Browser) SetBookmarksJar(bj jar.BookmarksJar) {
-	bow.bookmarks = bj
+// prepareCache adds the conditional request headers for the cached response
+// to the given request.
+func (bow *Browser) prepareCache(req *http.Request) {
+	if bow.cache == nil || req.Method != "GET" {
+		return
+	}
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+	if hasCacheDirective(req.Header, "no-cache") {
+		return
+	}
+	if e, ok := bow.cache.Get(req.URL.String()); ok {
+		if e.ETag != "" {
+			req.Header.Set("If-None-Match", e.ETag)
+		}
+		if e.LastModified != "" {
+			req.Header.Set("If-Modified-Since", e.LastModified)
+		}
+	}
 }
 
-// SetHistoryJar is used to set the history jar the browser uses.
-func (bow *Browser) SetHistoryJar(hj jar.History) {
-	bow.history = hj
+// cacheResponse stores the given response in the cache, or replaces a 304 Not
+// Modified response with the cached response. The returned boolean is whether
+// the cached response was used.
+func (bow *Browser) cacheResponse(req *http.Request, resp *http.Response) (*http.Response, bool, error) {
+	if bow.cache == nil || req.Method != "GET" {
+		return resp, false, nil
+	}
+	key := req.URL.String()
+	if resp.StatusCode == http.StatusNotModified {
+		e, ok := bow.cache.Get(key)
+		if !ok {
+			return resp, false, nil
+		}
+		bow.logDebug("Using cached response", "url", key)
+		resp.Body.Close()
+		resp.StatusCode = e.StatusCode
+		resp.Status = fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+		resp.Header = copyHeaders(e.Header)
+		resp.ContentLength = int64(len(e.Body))
+		resp.Body = ioutil.NopCloser(bytes.NewReader(e.Body))
+		return resp, true, nil
+	}
+	if hasCacheDirective(resp.Header, "no-store") || hasCacheDirective(req.Header, "no-store") {
+		bow.cache.Remove(key)
+		return resp, false, nil
+	}
+	etag, modified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || (etag == "" && modified == "") {
+		return resp, false, nil
+	}
+
+	body := resp.Body
+	if bow.maxResponseSize > 0 {
+		body = ioutil.NopCloser(io.LimitReader(resp.Body, bow.maxResponseSize+1))
+	}
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, false, err
+	}
+	if bow.maxResponseSize > 0 && int64(len(b)) > bow.maxResponseSize {
+		// Too large to cache. The body is left for parseResponse to reject.
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(b), resp.Body), resp.Body}
+		return resp, false, nil
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	bow.cache.Set(key, &jar.CacheEntry{
+		ETag:         etag,
+		LastModified: modified,
+		StatusCode:   resp.StatusCode,
+		Header:       copyHeaders(resp.Header),
+		Body:         b,
+	})
+	return resp, false, nil
 }
 
-// SetHeadersJar sets the headers the browser sends with each request.
-func (bow *Browser) SetHeadersJar(h http.Header) {
-	bow.headers = h
+// hasCacheDirective returns whether the Cache-Control header contains the
+// given directive.
+func hasCacheDirective(h http.Header, directive string) bool {
+	for _, v := range h["Cache-Control"] {
+		for _, d := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(d), directive) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-// AddRequestHeader sets a header the browser sends with each request.
-func (bow *Browser) AddRequestHeader(name, value string) {
-	bow.headers.Add(name, value)
+// prepareRequest dispatches the event.PreRequest event and calls the browser
+// middleware with the given request.
+//
+// Returns the request to send, which is not the given request when an
+// event.PreRequest handler replaced it.
+func (bow *Browser) prepareRequest(req *http.Request) (*http.Request, error) {
+	body := req.Body
+	args := []interface{}{req}
+	err := bow.Do(event.PreRequest, args...)
+	if err != nil {
+		return nil, err
+	}
+	if r, ok := args[0].(*http.Request); ok && r != nil {
+		req = r
+	}
+	err = bow.runMiddleware(req)
+	if err != nil {
+		return nil, err
+	}
+	err = rewindBody(req, body)
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
 }
 
-// ResolveUrl returns an absolute URL for a possibly relative URL.
-func (bow *Browser) ResolveUrl(u *url.URL) *url.URL {
-	return bow.Url().ResolveReference(u)
+// runMiddleware calls the browser middleware with the given request.
+func (bow *Browser) runMiddleware(req *http.Request) error {
+	for _, m := range bow.middleware {
+		if err := m(req); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// ResolveStringUrl works just like ResolveUrl, but the argument and return value are strings.
-func (bow *Browser) ResolveStringUrl(u string) (string, error) {
-	pu, err := url.Parse(u)
-	if err != nil {
-		return "", err
+// sendRequest sends the request, and retries it when it fails.
+//
+// Requests are retried up to the number of attempts set with SetRetry(), and
+// the delay between each attempt doubles.
+func (bow *Browser) sendRequest(req *http.Request, opts RequestOptions) (*http.Response, error) {
+	client := bow.buildClient()
+	if cj := cookieJar(opts.CookieJar); cj != nil {
+		client.Jar = cj
+	}
+	if bow.dryRun {
+		return bow.dryRunResponse(req, client.Jar), nil
+	}
+	backoff := bow.retryBackoff
+	for attempt := 0; ; attempt++ {
+		bow.throttle(req.URL.Host)
+		bow.addStat(&bow.requests)
+		resp, err := client.Do(req)
+		if attempt >= bow.retryAttempts || !bow.shouldRetry(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if req.GetBody != nil {
+			req.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+		bow.logDebug("Retrying request",
+			"method", req.Method,
+			"url", req.URL.String(),
+			"attempt", attempt+1,
+			"backoff", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		backoff *= 2
 	}
-	pu = bow.Url().ResolveReference(pu)
-	return pu.String(), nil
 }
 
-// Download writes the contents of the document to the given writer.
-func (bow *Browser) Download(o io.Writer) (int64, error) {
-	h, err := bow.state.Dom.Html()
-	if err != nil {
-		return 0, err
+// dryRunResponse records the request as the dry-run request, and returns an
+// empty response in place of sending it.
+//
+// The request body is read and closed, which releases a streamed body such as
+// the files sent by PostMultipartFiles(), and is replaced by a copy which can
+// be read from the recorded request.
+func (bow *Browser) dryRunResponse(req *http.Request, cj http.CookieJar) *http.Response {
+	if req.Body != nil && req.Body != http.NoBody {
+		b, _ := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		}
+	}
+	if cj != nil && req.Header.Get("Cookie") == "" {
+		for _, c := range cj.Cookies(req.URL) {
+			req.AddCookie(c)
+		}
+	}
+	bow.logDebug("Dry-run request",
+		"method", req.Method,
+		"url", req.URL.String())
+	bow.mu.Lock()
+	bow.dryRunRequest = req
+	bow.mu.Unlock()
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    200,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"text/html"}},
+		Body:          ioutil.NopCloser(strings.NewReader("")),
+		ContentLength: 0,
+		Request:       req,
 	}
-	l, err := io.WriteString(o, h)
-	return int64(l), err
 }
 
-// Url returns the page URL as a string.
-func (bow *Browser) Url() *url.URL {
-	return bow.state.Request.URL
+// addBytesRead adds n to the number of bytes read by the browser.
+func (bow *Browser) addBytesRead(n int64) {
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	bow.bytesRead += n
 }
 
-// StatusCode returns the response status code.
-func (bow *Browser) StatusCode() int {
-	return bow.state.Response.StatusCode
+// addStat adds 1 to the given counter of the browser stats.
+func (bow *Browser) addStat(n *int64) {
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	*n++
 }
 
-// Title returns the page title.
-func (bow *Browser) Title() string {
-	return bow.state.Dom.Find("title").Text()
+// countingReader counts the bytes read from a response body.
+type countingReader struct {
+	io.ReadCloser
+	n int64
 }
 
-// ResponseHeaders returns the page headers.
-func (bow *Browser) ResponseHeaders() http.Header {
-	return bow.state.Response.Header
+// Read reads from the body and counts the bytes read.
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
 }
 
-// Body returns the page body as a string of html.
-func (bow *Browser) Body() string {
-	body, _ := bow.state.Dom.Find("body").Html()
-	return body
+// streamReader adds the bytes read from a streamed body to the browser stats.
+type streamReader struct {
+	io.ReadCloser
+	bow *Browser
 }
 
-// Dom returns the inner *goquery.Selection.
-func (bow *Browser) Dom() *goquery.Selection {
-	return bow.state.Dom.First()
+// Read reads from the body and counts the bytes read.
+func (r *streamReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.bow.addBytesRead(int64(n))
+	return n, err
 }
 
-// Find returns the dom selections matching the given expression.
-func (bow *Browser) Find(expr string) *goquery.Selection {
-	return bow.state.Dom.Find(expr)
+// rateLimiter records the time of the requests made to each host.
+type rateLimiter struct {
+	mu   sync.Mutex
+	next map[string]time.Time
 }
 
-// -- Unexported methods --
+// reserve returns the time a request may be sent to the given host, and
+// records the request.
+func (l *rateLimiter) reserve(host string, limit time.Duration) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	at := time.Now()
+	if next, ok := l.next[host]; ok && next.After(at) {
+		at = next
+	}
+	l.next[host] = at.Add(limit)
+	return at
+}
 
-// buildClient creates, configures, and returns a *http.Client type.
-func (bow *Browser) buildClient() *http.Client {
-	client := &http.Client{}
-	client.Jar = bow.cookies
-	client.CheckRedirect = bow.shouldRedirect
-	return client
+// rateLimiter returns the rate limiter of the browser, creating it when needed.
+func (bow *Browser) rateLimiter() *rateLimiter {
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	if bow.limiter == nil {
+		bow.limiter = &rateLimiter{next: make(map[string]time.Time)}
+	}
+	return bow.limiter
 }
 
-// buildRequest creates and returns a *http.Request type.
-// Sets any headers that need to be sent with the request.
-func (bow *Browser) buildRequest(method, url string, ref *url.URL, body io.Reader) (*http.Request, error) {
-	req, err := http.NewRequest(method, url, body)
+// throttle waits until a request may be sent to the given host without
+// exceeding the rate limit.
+func (bow *Browser) throttle(host string) {
+	if bow.rateLimit <= 0 {
+		return
+	}
+	at := bow.rateLimiter().reserve(host, bow.rateLimit)
+	time.Sleep(time.Until(at))
+}
+
+// shouldRetry returns whether a request which received the given response and
+// error should be sent again.
+func (bow *Browser) shouldRetry(resp *http.Response, err error) bool {
 	if err != nil {
-		return nil, err
+		// A response is only returned with an error when a redirect was
+		// refused, which won't change by trying again.
+		return resp == nil
 	}
-	req.Header = bow.headers
-	req.Header.Add("User-Agent", bow.userAgent)
-	if bow.attributes[SendReferer] && ref != nil {
-		req.Header.Add("Referer", ref.String())
+	if bow.retryStatusCodes == nil {
+		return resp.StatusCode >= 500
 	}
+	for _, code := range bow.retryStatusCodes {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
 
-	return req, nil
+// preSend sets browser state before sending a request.
+func (bow *Browser) preSend() {
+	bow.StopMetaRefresh()
 }
 
-// httpGET makes an HTTP GET request for the given URL.
-// When via is not nil, and AttributeSendReferer is true, the Referer header will
-// be set to ref.
-func (bow *Browser) httpGET(u *url.URL, ref *url.URL) error {
-	req, err := bow.buildRequest("GET", u.String(), ref, nil)
-	if err != nil {
+// postSend sets browser state after sending a request.
+func (bow *Browser) postSend() {
+	if bow.attribute(MetaRefreshHandling) {
+		dur, u, ok := bow.metaRefresh()
+		if ok {
+			bow.logDebug("Creating meta refresh timer",
+				"url", bow.Url().String(),
+				"seconds", dur.Seconds())
+			bow.mu.Lock()
+			bow.refreshSeq++
+			seq := bow.refreshSeq
+			bow.refresh = time.AfterFunc(dur, func() {
+				if bow.takeRefresh(seq) {
+					bow.followRefresh(u)
+				}
+			})
+			bow.mu.Unlock()
+		}
+	}
+}
+
+// takeRefresh returns whether the meta refresh timer with the given sequence
+// number is still pending, and clears it when it is.
+//
+// A timer which fires after it has been stopped, or replaced by the timer for
+// another page, must not refresh the page.
+func (bow *Browser) takeRefresh(seq int) bool {
+	bow.mu.Lock()
+	defer bow.mu.Unlock()
+	if bow.refresh == nil || bow.refreshSeq != seq {
+		return false
+	}
+	bow.refresh = nil
+	return true
+}
+
+// metaRefresh returns the delay and the URL from the refresh meta tag.
+//
+// The tag content is either a number of seconds, eg "5", or a number of seconds
+// and a URL, eg "5; url=/page2". The URL is the page URL when the content does
+// not have one. Returns false when the page does not have a valid refresh meta
+// tag.
+func (bow *Browser) metaRefresh() (time.Duration, *url.URL, bool) {
+	sel := bow.Find("meta[http-equiv='refresh']")
+	content, ok := sel.Attr("content")
+	if !ok {
+		return 0, nil, false
+	}
+
+	delay, target := content, ""
+	if i := strings.IndexAny(content, ";,"); i != -1 {
+		delay, target = content[:i], strings.TrimSpace(content[i+1:])
+	}
+	secs, err := strconv.ParseFloat(strings.TrimSpace(delay), 64)
+	if err != nil || secs < 0 {
+		return 0, nil, false
+	}
+	if len(target) > 4 && strings.EqualFold(target[:4], "url=") {
+		target = strings.TrimSpace(target[4:])
+	}
+	target = strings.Trim(target, `'"`)
+
+	u := bow.Url()
+	if target != "" {
+		tu, err := url.Parse(target)
+		if err != nil {
+			return 0, nil, false
+		}
+		u = bow.ResolveUrl(tu)
+	}
+
+	return time.Duration(secs * float64(time.Second)), u, true
+}
+
+// followRefresh loads the given meta refresh URL.
+// The page is reloaded when the URL is the page URL.
+func (bow *Browser) followRefresh(u *url.URL) error {
+	page := bow.Url()
+	if u.String() == page.String() {
+		return bow.Reload()
+	}
+	return bow.httpGET(u, page)
+}
+
+// cookieJar returns the given cookie jar, or nil when the jar is a nil
+// pointer, which would otherwise panic when the client uses it.
+func cookieJar(cj http.CookieJar) http.CookieJar {
+	if cj == nil {
+		return nil
+	}
+	if v := reflect.ValueOf(cj); v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil
+	}
+	return cj
+}
+
+// dispatchCookies dispatches the event.SetCookie event when the response sets cookies.
+func (bow *Browser) dispatchCookies(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	if cookies := resp.Cookies(); len(cookies) > 0 {
+		bow.Do(event.SetCookie, cookies, resp)
+	}
+}
+
+// requestError counts the failed request, dispatches the event.Error event and
+// returns the given error.
+func (bow *Browser) requestError(req *http.Request, err error) error {
+	bow.addStat(&bow.errors)
+	bow.logError("Request failed",
+		"method", req.Method,
+		"url", req.URL.String(),
+		"error", err)
+	bow.Do(event.Error, err, req)
+	return err
+}
+
+// logDebug logs a message at the debug level when a logger has been set.
+func (bow *Browser) logDebug(msg string, keyvals ...interface{}) {
+	if bow.logger != nil {
+		bow.logger.Debug(msg, keyvals...)
+	}
+}
+
+// logInfo logs a message at the info level when a logger has been set.
+func (bow *Browser) logInfo(msg string, keyvals ...interface{}) {
+	if bow.logger != nil {
+		bow.logger.Info(msg, keyvals...)
+	}
+}
+
+// logError logs a message at the error level when a logger has been set.
+func (bow *Browser) logError(msg string, keyvals ...interface{}) {
+	if bow.logger != nil {
+		bow.logger.Error(msg, keyvals...)
+	}
+}
+
+// shouldRedirect is used as the value to http.Client.CheckRedirect.
+//
+// Dispatches the event.Redirect event before the redirect is followed. A
+// handler may stop the redirect by returning an error.
+func (bow *Browser) shouldRedirect(req *http.Request, via []*http.Request) error {
+	bow.dispatchCookies(req.Response)
+	if !bow.attribute(FollowRedirects) {
+		return errors.NewLocation(
+			"Redirects are disabled. Cannot follow '%s'.", req.URL.String())
+	}
+	bow.redirectHeaders(req, via[0])
+	if bow.redirectPolicy != nil {
+		if err := bow.redirectPolicy(req, via); err != nil {
+			return err
+		}
+	}
+	if err := bow.Do(event.Redirect, req, via); err != nil {
 		return err
 	}
-	return bow.httpRequest(req)
+	bow.addStat(&bow.redirects)
+	bow.addStat(&bow.requests)
+	return nil
 }
 
-// httpPOST makes an HTTP POST request for the given URL.
-// When via is not nil, and AttributeSendReferer is true, the Referer header will
-// be set to ref.
-func (bow *Browser) httpPOST(u *url.URL, ref *url.URL, contentType string, body io.Reader) error {
-	req, err := bow.buildRequest("POST", u.String(), ref, body)
+// redirectHeaders sets the headers of a redirect to the given request.
+//
+// The client copies the headers of the original request to the redirect. When
+// the redirect is to another host, the headers set with SetHostHeaders() for the
+// original host are replaced with the headers for the new host, and the
+// sensitive headers are removed when the StripCrossHostHeaders attribute is true.
+func (bow *Browser) redirectHeaders(req, orig *http.Request) {
+	if strings.EqualFold(req.URL.Host, orig.URL.Host) {
+		return
+	}
+	headers := bow.requestHeaders()
+	for k := range bow.headersForHost(orig.URL) {
+		req.Header.Del(k)
+		if v, ok := headers[k]; ok {
+			req.Header[k] = append([]string(nil), v...)
+		}
+	}
+	for k, v := range bow.headersForHost(req.URL) {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	if bow.attribute(StripCrossHostHeaders) {
+		req.Header.Del("Authorization")
+		req.Header.Del("Proxy-Authorization")
+		req.Header.Del("Cookie")
+	}
+}
+
+// writeMultipart writes the fields and files to w in multipart/form-data format.
+func writeMultipart(w io.Writer, boundary string, fields url.Values, files map[string]string) error {
+	writer := multipart.NewWriter(w)
+	err := writer.SetBoundary(boundary)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Content-Type", contentType)
+	for k, vs := range fields {
+		for _, v := range vs {
+			err = writer.WriteField(k, v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for field, name := range files {
+		err = writeMultipartFile(writer, field, name)
+		if err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
 
-	return bow.httpRequest(req)
+// multipartBody returns the given fields encoded as a multipart/form-data body,
+// and the content type of the body.
+func multipartBody(fields url.Values) (io.Reader, string, error) {
+	body := &bytes.Buffer{}
+	boundary := multipart.NewWriter(nil).Boundary()
+	err := writeMultipart(body, boundary, fields, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, "multipart/form-data; boundary=" + boundary, nil
 }
 
-// send uses the given *http.Request to make an HTTP request.
-func (bow *Browser) httpRequest(req *http.Request) error {
-	bow.preSend()
-	resp, err := bow.buildClient().Do(req)
+// writeMultipartFile writes the file with the given name to the multipart writer.
+func writeMultipartFile(writer *multipart.Writer, field, name string) error {
+	fin, err := os.Open(name)
 	if err != nil {
 		return err
 	}
-	dom, err := goquery.NewDocumentFromResponse(resp)
+	defer fin.Close()
+	part, err := writer.CreateFormFile(field, filepath.Base(name))
 	if err != nil {
 		return err
 	}
-	bow.history.Push(bow.state)
-	bow.state = jar.NewHistoryState(req, resp, dom)
-	bow.postSend()
+	_, err = io.Copy(part, fin)
+	return err
+}
+
+// normalizeLanguage returns the given language tag with the case and
+// separators used by BCP 47, eg "en_us" becomes "en-US".
+func normalizeLanguage(lang string) string {
+	parts := strings.Split(strings.Replace(strings.TrimSpace(lang), "_", "-", -1), "-")
+	if parts[0] == "" {
+		return ""
+	}
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		switch len(parts[i]) {
+		case 2:
+			parts[i] = strings.ToUpper(parts[i])
+		case 4:
+			parts[i] = strings.ToUpper(parts[i][:1]) + strings.ToLower(parts[i][1:])
+		default:
+			parts[i] = strings.ToLower(parts[i])
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// shellQuote quotes the given string for use as a single shell argument.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// isSensitiveHeader returns whether the header with the given name may contain
+// credentials.
+func isSensitiveHeader(name string) bool {
+	switch http.CanonicalHeaderKey(name) {
+	case "Authorization", "Proxy-Authorization", "Cookie":
+		return true
+	}
+	return false
+}
 
+// rewindBody replaces the body of the request with a fresh copy when it is
+// still the given body, which the event handlers and middleware may have read.
+// A body replaced by the handlers is kept.
+func rewindBody(req *http.Request, body io.ReadCloser) error {
+	if body == nil || body == http.NoBody || req.Body != body || req.GetBody == nil {
+		return nil
+	}
+	body.Close()
+	b, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	req.Body = b
 	return nil
 }
 
-// preSend sets browser state before sending a request.
-func (bow *Browser) preSend() {
-	if bow.refresh != nil {
-		bow.refresh.Stop()
+// replayableBody returns a request body which can be read more than once.
+//
+// The http package can only replay bodies of type *bytes.Buffer, *bytes.Reader,
+// and *strings.Reader, which is needed to follow 307 and 308 redirects. Any
+// other type of body is read into memory.
+func replayableBody(body io.Reader) (io.Reader, error) {
+	switch body.(type) {
+	case nil, *bytes.Buffer, *bytes.Reader, *strings.Reader:
+		return body, nil
 	}
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
 }
 
-// postSend sets browser state after sending a request.
-func (bow *Browser) postSend() {
-	if bow.attributes[MetaRefreshHandling] {
-		sel := bow.Find("meta[http-equiv='refresh']")
-		if sel.Length() > 0 {
-			attr, ok := sel.Attr("content")
-			if ok {
-				dur, err := time.ParseDuration(attr + "s")
-				if err == nil {
-					bow.refresh = time.NewTimer(dur)
-					go func() {
-						<-bow.refresh.C
-						bow.Reload()
-					}()
-				}
-			}
-		}
+// uniqueFileName returns a file name based on the given name which is not in
+// the used names, and adds it to the used names.
+//
+// A number is added to the name when it has already been used, eg "image.png"
+// becomes "image-1.png".
+func uniqueFileName(name string, used map[string]bool) string {
+	if name == "." || name == "/" || name == "" {
+		name = "asset"
 	}
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; used[name]; i++ {
+		name = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+	used[name] = true
+	return name
 }
 
-// shouldRedirect is used as the value to http.Client.CheckRedirect.
-func (bow *Browser) shouldRedirect(req *http.Request, _ []*http.Request) error {
-	if bow.attributes[FollowRedirects] {
-		return nil
+// copyHeaders returns a copy of the given headers.
+func copyHeaders(h http.Header) http.Header {
+	c := make(http.Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
 	}
-	return errors.NewLocation(
-		"Redirects are disabled. Cannot follow '%s'.", req.URL.String())
+	return c
 }
 
 // attributeToUrl reads an attribute from an element and returns a url.
@@ -608,6 +4157,25 @@ func (bow *Browser) attrToResolvedUrl(name string, sel *goquery.Selection) (*url
 	return bow.ResolveUrl(ur), nil
 }
 
+// followRel loads the page linked to by the first link or anchor with one of
+// the given rel values.
+func (bow *Browser) followRel(rels ...string) error {
+	exprs := make([]string, 0, len(rels)*2)
+	for _, rel := range rels {
+		exprs = append(exprs, fmt.Sprintf("link[rel~=%q][href],a[rel~=%q][href]", rel, rel))
+	}
+	sel := bow.Find(strings.Join(exprs, ",")).First()
+	if sel.Length() == 0 {
+		return errors.NewElementNotFound(
+			"No link found with rel '%s'.", rels[0])
+	}
+	href, err := bow.attrToResolvedUrl("href", sel)
+	if err != nil {
+		return err
+	}
+	return bow.httpGET(href, bow.Url())
+}
+
 // attributeOrDefault reads an attribute and returns it or the default value when it's empty.
 func (bow *Browser) attrOrDefault(name, def string, sel *goquery.Selection) string {
 	a, ok := sel.Attr(name)
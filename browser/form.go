@@ -1,6 +1,9 @@
 package browser
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/headzoo/surf/errors"
 	"net/url"
@@ -9,11 +12,42 @@ import (
 
 // Submittable represents an element that may be submitted, such as a form.
 type Submittable interface {
+	// Method returns the form method, eg "GET" or "POST". Defaults to "GET".
 	Method() string
+
+	// Action returns the absolute URL the form is submitted to. Defaults to
+	// the page URL.
 	Action() string
+
+	// ActionURL returns the absolute URL the form is submitted to as a *url.URL,
+	// or nil when the action is not a valid URL.
+	ActionURL() *url.URL
+
 	Input(name, value string) error
+
+	// Set sets the value of a form field. Returns an error when the form does
+	// not have a field with the given name.
+	Set(name, value string) error
+
+	// SetAny sets the value of a form field, adding the field when the form
+	// does not have a field with the given name.
+	SetAny(name, value string)
+
+	// SetMulti sets the values of a form field which is submitted more than once.
+	// Returns an error when the form does not have a field with the given name.
+	SetMulti(name string, values ...string) error
+
+	// FileFields returns the names of the file inputs in the form.
+	FileFields() []string
+
+	// PreviewURL returns the URL the form is submitted to, including the
+	// field values of a form submitted with the GET method.
+	PreviewURL() (*url.URL, error)
+
 	Click(button string) error
 	Submit() error
+	SubmitContext(ctx context.Context) error
+	SubmitJSON() error
 	Dom() *goquery.Selection
 }
 
@@ -30,16 +64,17 @@ type Form struct {
 // NewForm creates and returns a *Form type.
 func NewForm(bow Browsable, s *goquery.Selection) *Form {
 	fields, buttons := serializeForm(s)
-	method, action := formAttributes(bow, s)
-
-	return &Form{
+	f := &Form{
 		bow:       bow,
 		selection: s,
-		method:    method,
-		action:    action,
 		fields:    fields,
 		buttons:   buttons,
 	}
+	if method, aurl, err := f.target(""); err == nil {
+		f.method, f.action = method, aurl.String()
+	}
+
+	return f
 }
 
 // Method returns the form method, eg "GET" or "POST".
@@ -53,8 +88,35 @@ func (f *Form) Action() string {
 	return f.action
 }
 
+// ActionURL returns the form action URL as a *url.URL.
+//
+// ActionURL exists because Action() returns a string, and changing its
+// signature would break the callers and implementations of Submittable. The
+// URL is resolved the same way as when the form is submitted without a button,
+// so it will always be absolute. Submitting with a button which has a
+// formaction attribute sends the form to that URL instead. A new URL is
+// returned by each call so it may be changed by the caller. Returns nil when
+// the form action is not a valid URL, in which case Action() returns an empty
+// string.
+func (f *Form) ActionURL() *url.URL {
+	_, u, err := f.target("")
+	if err != nil {
+		return nil
+	}
+	return u
+}
+
 // Input sets the value of a form field.
+// It is the same as Set().
 func (f *Form) Input(name, value string) error {
+	return f.Set(name, value)
+}
+
+// Set sets the value of a form field.
+// Returns an ElementNotFound error when the form does not have an input with
+// the given name, which catches typos in field names before the form is
+// submitted.
+func (f *Form) Set(name, value string) error {
 	if _, ok := f.fields[name]; ok {
 		f.fields.Set(name, value)
 		return nil
@@ -63,18 +125,93 @@ func (f *Form) Input(name, value string) error {
 		"No input found with name '%s'.", name)
 }
 
+// SetMulti sets the values of a form field which is submitted more than once.
+// The values replace the values of every input with the given name, eg a group
+// of checkboxes. Returns an ElementNotFound error when the form does not have
+// an input with the given name.
+func (f *Form) SetMulti(name string, values ...string) error {
+	if _, ok := f.fields[name]; ok {
+		f.fields[name] = append([]string{}, values...)
+		return nil
+	}
+	return errors.NewElementNotFound(
+		"No input found with name '%s'.", name)
+}
+
+// SetAny sets the value of a form field.
+// The field is added to the submitted values when the form does not have an
+// input with the given name, eg for fields added to the form by JavaScript.
+func (f *Form) SetAny(name, value string) {
+	f.fields.Set(name, value)
+}
+
+// FileFields returns the names of the file inputs in the form.
+// The names are returned in the order the inputs appear in the form, and
+// inputs without a name are skipped. Returns an empty slice when the form
+// does not have any file inputs.
+func (f *Form) FileFields() []string {
+	names := []string{}
+	seen := make(map[string]bool)
+	f.selection.Find("input[type='file'][name]").Each(func(_ int, s *goquery.Selection) {
+		name, _ := s.Attr("name")
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	})
+	return names
+}
+
 // Submit submits the form.
 // Clicks the first button in the form, or submits the form without using
 // any button when the form does not contain any buttons.
 func (f *Form) Submit() error {
-	if len(f.buttons) > 0 {
-		for name := range f.buttons {
-			return f.Click(name)
-		}
+	if name, _ := f.firstButton(); name != "" {
+		return f.Click(name)
 	}
 	return f.send("", "")
 }
 
+// SubmitContext submits the form with the given context.
+//
+// The form is submitted the same way as Submit(), and the request is stopped
+// when the context is cancelled.
+func (f *Form) SubmitContext(ctx context.Context) error {
+	buttonName, buttonValue := f.firstButton()
+	return f.sendContext(ctx, buttonName, buttonValue)
+}
+
+// SubmitJSON submits the form fields as a JSON object.
+//
+// The fields are posted to the form action with the "application/json" content
+// type, whatever the form method. Each field becomes a string value in the
+// object, and fields with more than one value become an array of strings. The
+// first button in the form is included the same way as Submit().
+func (f *Form) SubmitJSON() error {
+	buttonName, buttonValue := f.firstButton()
+	_, aurl, err := f.target(buttonName)
+	if err != nil {
+		return err
+	}
+
+	obj := make(map[string]interface{}, len(f.fields)+1)
+	for name, vals := range f.values(buttonName, buttonValue) {
+		if len(vals) == 0 {
+			continue
+		}
+		if len(vals) == 1 {
+			obj[name] = vals[0]
+		} else {
+			obj[name] = vals
+		}
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return f.bow.Post(aurl.String(), "application/json", bytes.NewReader(b))
+}
+
 // Click submits the form by clicking the button with the given name.
 func (f *Form) Click(button string) error {
 	if _, ok := f.buttons[button]; !ok {
@@ -91,6 +228,87 @@ func (f *Form) Dom() *goquery.Selection {
 
 // send submits the form.
 func (f *Form) send(buttonName, buttonValue string) error {
+	return f.sendContext(context.Background(), buttonName, buttonValue)
+}
+
+// sendContext submits the form with the given context.
+func (f *Form) sendContext(ctx context.Context, buttonName, buttonValue string) error {
+	method, aurl, err := f.target(buttonName)
+	if err != nil {
+		return err
+	}
+	values := f.values(buttonName, buttonValue)
+
+	if method == "GET" {
+		return f.bow.OpenContext(ctx, withQuery(aurl, values).String())
+	}
+	enctype, _ := f.selection.Attr("enctype")
+	if enctype == "multipart/form-data" {
+		body, contentType, err := multipartBody(values)
+		if err != nil {
+			return err
+		}
+		return f.bow.PostContext(ctx, aurl.String(), contentType, body)
+	}
+	return f.bow.PostContext(ctx, aurl.String(),
+		"application/x-www-form-urlencoded", strings.NewReader(values.Encode()))
+}
+
+// PreviewURL returns the URL the form is submitted to, without submitting it.
+//
+// The URL is the absolute action URL the form is submitted to by Submit(). The
+// current field values are appended to the query string when the form is
+// submitted with the GET method, and are not included for other methods since
+// they are sent in the request body.
+func (f *Form) PreviewURL() (*url.URL, error) {
+	buttonName, buttonValue := f.firstButton()
+	method, aurl, err := f.target(buttonName)
+	if err != nil {
+		return nil, err
+	}
+	if method == "GET" {
+		aurl = withQuery(aurl, f.values(buttonName, buttonValue))
+	}
+	return aurl, nil
+}
+
+// firstButton returns the name and value of the first submit button in the
+// form, or empty strings when the form does not have any buttons.
+func (f *Form) firstButton() (string, string) {
+	name := ""
+	f.selection.Find("input[type='submit'][name],button[type='submit'][name]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		n, _ := s.Attr("name")
+		if _, ok := f.buttons[n]; ok {
+			name = n
+			return false
+		}
+		return true
+	})
+	if name == "" {
+		return "", ""
+	}
+	return name, f.buttons[name][0]
+}
+
+// withQuery returns a copy of the given URL with the values merged into the
+// query string. A value replaces every value in the query string with the
+// same name.
+func withQuery(u *url.URL, values url.Values) *url.URL {
+	c := *u
+	query := c.Query()
+	for name, vals := range values {
+		query[name] = vals
+	}
+	c.RawQuery = query.Encode()
+	return &c
+}
+
+// target returns the method and absolute URL used to submit the form with the
+// given button.
+//
+// The formmethod and formaction attributes of the button override the method
+// and action of the form.
+func (f *Form) target(buttonName string) (string, *url.URL, error) {
 	method, ok := f.selection.Attr("method")
 	if !ok {
 		method = "GET"
@@ -99,12 +317,30 @@ func (f *Form) send(buttonName, buttonValue string) error {
 	if !ok {
 		action = f.bow.Url().String()
 	}
+	if buttonName != "" {
+		f.selection.Find("input,button").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+			if name, _ := s.Attr("name"); name != buttonName {
+				return true
+			}
+			if fm, ok := s.Attr("formmethod"); ok {
+				method = fm
+			}
+			if fa, ok := s.Attr("formaction"); ok {
+				action = fa
+			}
+			return false
+		})
+	}
 	aurl, err := url.Parse(action)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
-	aurl = f.bow.ResolveUrl(aurl)
+	return strings.ToUpper(method), f.bow.ResolveUrl(aurl), nil
+}
 
+// values returns the form field values, and the value of the given button when
+// the name is not empty.
+func (f *Form) values(buttonName, buttonValue string) url.Values {
 	values := make(url.Values, len(f.fields)+1)
 	for name, vals := range f.fields {
 		values[name] = vals
@@ -112,25 +348,21 @@ func (f *Form) send(buttonName, buttonValue string) error {
 	if buttonName != "" {
 		values.Set(buttonName, buttonValue)
 	}
-
-	if strings.ToUpper(method) == "GET" {
-		return f.bow.OpenForm(aurl.String(), values)
-	} else {
-		enctype, _ := f.selection.Attr("enctype")
-		if enctype == "multipart/form-data" {
-			return f.bow.PostMultipart(aurl.String(), values)
-		}
-		return f.bow.PostForm(aurl.String(), values)
-	}
-
-	return nil
+	return values
 }
 
 // Serialize converts the form fields into a url.Values type.
 // Returns two url.Value types. The first is the form field values, and the
 // second is the form button values.
+//
+// Inputs with the same name are collected into the same field, and inputs
+// without a type attribute are text inputs. Checkboxes and radio buttons are
+// only submitted when they are checked, and the fields of unchecked inputs have
+// no values so they can still be set. A select submits the value of each
+// selected option, or of its first option when none are selected and it does
+// not have the multiple attribute.
 func serializeForm(sel *goquery.Selection) (url.Values, url.Values) {
-	input := sel.Find("input,button")
+	input := sel.Find("input,button,select")
 	if input.Length() == 0 {
 		return url.Values{}, url.Values{}
 	}
@@ -139,8 +371,26 @@ func serializeForm(sel *goquery.Selection) (url.Values, url.Values) {
 	buttons := make(url.Values)
 	input.Each(func(_ int, s *goquery.Selection) {
 		name, ok := s.Attr("name")
-		if ok {
+		if ok && s.Is("select") {
+			if _, ok := fields[name]; !ok {
+				fields[name] = []string{}
+			}
+			options := s.Find("option[selected]")
+			if options.Length() == 0 && !s.Is("[multiple]") {
+				options = s.Find("option").First()
+			}
+			options.Each(func(_ int, o *goquery.Selection) {
+				val, ok := o.Attr("value")
+				if !ok {
+					val = strings.TrimSpace(o.Text())
+				}
+				fields.Add(name, val)
+			})
+		} else if ok {
 			typ, ok := s.Attr("type")
+			if !ok && s.Is("input") {
+				typ, ok = "text", true
+			}
 			if ok {
 				if typ == "submit" {
 					val, ok := s.Attr("value")
@@ -149,10 +399,17 @@ func serializeForm(sel *goquery.Selection) (url.Values, url.Values) {
 					} else {
 						buttons.Add(name, "")
 					}
+				} else if (typ == "checkbox" || typ == "radio") && !s.Is("[checked]") {
+					if _, ok := fields[name]; !ok {
+						fields[name] = []string{}
+					}
 				} else {
 					val, ok := s.Attr("value")
 					if !ok {
 						val = ""
+						if typ == "checkbox" || typ == "radio" {
+							val = "on"
+						}
 					}
 					fields.Add(name, val)
 				}
@@ -162,21 +419,3 @@ func serializeForm(sel *goquery.Selection) (url.Values, url.Values) {
 
 	return fields, buttons
 }
-
-func formAttributes(bow Browsable, s *goquery.Selection) (string, string) {
-	method, ok := s.Attr("method")
-	if !ok {
-		method = "GET"
-	}
-	action, ok := s.Attr("action")
-	if !ok {
-		action = bow.Url().String()
-	}
-	aurl, err := url.Parse(action)
-	if err != nil {
-		return "", ""
-	}
-	aurl = bow.ResolveUrl(aurl)
-
-	return strings.ToUpper(method), aurl.String()
-}
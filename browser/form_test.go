@@ -1,12 +1,17 @@
 package browser
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"github.com/headzoo/surf/errors"
 	"github.com/headzoo/surf/jar"
 	"github.com/headzoo/ut"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 )
 
 func TestBrowserForm(t *testing.T) {
@@ -40,6 +45,587 @@ func TestBrowserForm(t *testing.T) {
 	ut.AssertContains("submit2=submitted2", bow.Body())
 }
 
+func TestBrowserFormAttributes(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, htmlFormAttributes)
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	err := bow.Open(ts.URL + "/forms/")
+	ut.AssertNil(err)
+
+	f, err := bow.Form("[name='explicit']")
+	ut.AssertNil(err)
+	ut.AssertEquals("POST", f.Method())
+	ut.AssertEquals(ts.URL+"/forms/submit", f.Action())
+	ut.AssertEquals(ts.URL+"/forms/submit", f.ActionURL().String())
+	ut.AssertEquals("/forms/submit", f.ActionURL().Path)
+
+	f, err = bow.Form("[name='default']")
+	ut.AssertNil(err)
+	ut.AssertEquals("GET", f.Method())
+	ut.AssertEquals(ts.URL+"/forms/", f.Action())
+	ut.AssertEquals(ts.URL+"/forms/", f.ActionURL().String())
+
+	f, err = bow.Form("[name='invalid']")
+	ut.AssertNil(err)
+	ut.AssertEquals("", f.Action())
+	ut.AssertNil(f.ActionURL())
+}
+
+func TestBrowserFormButtonOverrides(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, htmlFormOverrides)
+			return
+		}
+		r.ParseForm()
+		fmt.Fprint(w, r.Method+" "+r.URL.Path+" "+r.Form.Encode())
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	f, err := bow.Form("form")
+	ut.AssertNil(err)
+	err = f.Click("save")
+	ut.AssertNil(err)
+	ut.AssertEquals("POST /save age=&amp;save=saved", bow.Body())
+
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	f, err = bow.Form("form")
+	ut.AssertNil(err)
+	err = f.Click("preview")
+	ut.AssertNil(err)
+	ut.AssertEquals("GET /preview age=&amp;preview=previewed", bow.Body())
+}
+
+func TestBrowserFormSubmitJSON(t *testing.T) {
+	ut.Run(t)
+	var contentType string
+	var body map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprint(w, htmlFormJSON)
+		} else {
+			contentType = r.Header.Get("Content-Type")
+			json.NewDecoder(r.Body).Decode(&body)
+			fmt.Fprint(w, htmlForm)
+		}
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	f, err := bow.Form("[name='json']")
+	ut.AssertNil(err)
+	f.Input("age", "55")
+	err = f.SubmitJSON()
+	ut.AssertNil(err)
+	ut.AssertEquals("application/json", contentType)
+	ut.AssertEquals(map[string]interface{}{
+		"age":    "55",
+		"tags":   []interface{}{"go", "surf"},
+		"submit": "submitted",
+	}, body)
+}
+
+func TestBrowserFormSubmitJSONButtons(t *testing.T) {
+	ut.Run(t)
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprint(w, htmlFormButtons)
+			return
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		requested = append(requested, r.URL.Path+" "+body["first"]+body["second"])
+		fmt.Fprint(w, "ok")
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	for i := 0; i < 10; i++ {
+		ut.AssertNil(bow.Open(ts.URL))
+		f, err := bow.Form("form[name='mixed']")
+		ut.AssertNil(err)
+		ut.AssertNil(f.SubmitJSON())
+		ut.AssertEquals("/first 1", requested[i])
+	}
+}
+
+func TestBrowserFormSubmitContext(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/json" {
+			fmt.Fprint(w, htmlFormJSON)
+			return
+		}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	f, err := bow.Form("[name='json']")
+	ut.AssertNil(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = f.SubmitContext(ctx)
+	ut.AssertNotNil(err)
+	ut.AssertTrue(time.Since(start) < time.Second)
+	ut.AssertEquals("Echo Form", bow.Title())
+}
+
+func TestBrowserFormSet(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprint(w, htmlFormLogin)
+		} else {
+			r.ParseForm()
+			fmt.Fprint(w, r.PostForm.Encode())
+		}
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	f, err := bow.Form("form[name='login']")
+	ut.AssertNil(err)
+
+	ut.AssertNil(f.Set("username", "surf"))
+	ut.AssertNil(f.Set("password", "secret"))
+	err = f.Set("pasword", "typo")
+	ut.AssertNotNil(err)
+	ut.AssertContains("pasword", err.Error())
+	f.SetAny("remember", "yes")
+
+	err = f.Submit()
+	ut.AssertNil(err)
+	ut.AssertEquals(
+		"login=Login&password=secret&remember=yes&token=abc&username=surf",
+		bow.Find("body").Text())
+}
+
+func TestBrowserFormUntypedInput(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, htmlFormUntyped)
+		} else {
+			fmt.Fprint(w, r.URL.RawQuery)
+		}
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	f, err := bow.Form("form[name='search']")
+	ut.AssertNil(err)
+	ut.AssertNil(f.Set("q", "surf"))
+	err = f.Submit()
+	ut.AssertNil(err)
+	ut.AssertEquals("lang=en&q=surf", bow.Find("body").Text())
+
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	err = bow.SubmitForm("form[name='search']", url.Values{"q": {"go"}})
+	ut.AssertNil(err)
+	ut.AssertEquals("lang=en&q=go", bow.Find("body").Text())
+}
+
+func TestBrowserFormCheckboxes(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprint(w, htmlFormCheckboxes)
+		} else {
+			r.ParseForm()
+			fmt.Fprint(w, r.PostForm.Encode())
+		}
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	f, err := bow.Form("form")
+	ut.AssertNil(err)
+	err = f.Submit()
+	ut.AssertNil(err)
+	ut.AssertEquals("lang=go&lang=rust&submit=Save", bow.Find("body").Text())
+
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	f, err = bow.Form("form")
+	ut.AssertNil(err)
+	ut.AssertNil(f.SetMulti("lang", "go", "python", "rust"))
+	ut.AssertNil(f.Set("newsletter", "on"))
+	ut.AssertNotNil(f.SetMulti("langs", "go"))
+	err = f.Submit()
+	ut.AssertNil(err)
+	ut.AssertEquals(
+		"lang=go&lang=python&lang=rust&newsletter=on&submit=Save",
+		bow.Find("body").Text())
+}
+
+func TestBrowserFillForm(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprint(w, htmlFormLogin)
+		} else {
+			r.ParseForm()
+			fmt.Fprint(w, r.PostForm.Encode())
+		}
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	_, err = bow.FillForm("form[name='login']", url.Values{
+		"username": {"surf"},
+		"email":    {"surf@example.com"},
+	})
+	ut.AssertNotNil(err)
+
+	f, err := bow.FillForm("form[name='login']", url.Values{
+		"username": {"surf"},
+		"password": {"secret"},
+	})
+	ut.AssertNil(err)
+	err = f.Submit()
+	ut.AssertNil(err)
+	ut.AssertEquals("login=Login&password=secret&token=abc&username=surf", bow.Find("body").Text())
+
+	_, err = bow.FillForm("form[name='missing']", url.Values{})
+	ut.AssertNotNil(err)
+}
+
+func TestBrowserSubmitForm(t *testing.T) {
+	ut.Run(t)
+	posts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprint(w, htmlFormLogin)
+			return
+		}
+		posts++
+		if r.PostFormValue("username") == "surf" && r.PostFormValue("password") == "secret" {
+			fmt.Fprint(w, "Welcome, surf!")
+		} else {
+			fmt.Fprint(w, "Invalid login.")
+		}
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	err = bow.SubmitForm("form[name='login']", url.Values{
+		"username": {"surf"},
+		"password": {"secret"},
+	})
+	ut.AssertNil(err)
+	ut.AssertEquals("Welcome, surf!", bow.Find("body").Text())
+	ut.AssertEquals(1, posts)
+
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	err = bow.SubmitForm("form[name='login']", url.Values{
+		"username": {"surf"},
+		"email":    {"surf@example.com"},
+	})
+	ut.AssertNotNil(err)
+	_, ok := err.(errors.ElementNotFound)
+	ut.AssertTrue(ok)
+	err = bow.SubmitForm("form[name='missing']", url.Values{})
+	ut.AssertNotNil(err)
+	ut.AssertEquals(1, posts)
+}
+
+func TestBrowserFormFileFields(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, htmlFormUpload)
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	f, err := bow.Form("form[name='upload']")
+	ut.AssertNil(err)
+	ut.AssertEquals([]string{"avatar", "resume"}, f.FileFields())
+
+	f, err = bow.Form("form[name='login']")
+	ut.AssertNil(err)
+	ut.AssertEquals([]string{}, f.FileFields())
+}
+
+func TestBrowserFormGetRepeatedFields(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, htmlFormRepeated)
+		} else {
+			fmt.Fprint(w, r.URL.RawQuery)
+		}
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	f, err := bow.Form("form")
+	ut.AssertNil(err)
+	err = f.Submit()
+	ut.AssertNil(err)
+	ut.AssertEquals("lang=go&lang=rust&size=m&tag=a&tag=b&topic=web&topic=cli", bow.Find("body").Text())
+
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	f, err = bow.Form("form")
+	ut.AssertNil(err)
+	ut.AssertNil(f.SetMulti("topic", "db", "web", "db"))
+	err = f.Submit()
+	ut.AssertNil(err)
+	ut.AssertEquals("lang=go&lang=rust&size=m&tag=a&tag=b&topic=db&topic=web&topic=db", bow.Find("body").Text())
+
+	err = bow.OpenForm(ts.URL+"/search?tag=x", url.Values{"tag": {"a", "b"}, "q": {"surf"}})
+	ut.AssertNil(err)
+	ut.AssertEquals("q=surf&tag=a&tag=b", bow.Find("body").Text())
+}
+
+func TestBrowserFormPreviewURL(t *testing.T) {
+	ut.Run(t)
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, htmlFormPreview)
+			return
+		}
+		requested = append(requested, r.URL.String())
+		fmt.Fprint(w, "ok")
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	f, err := bow.Form("form[name='search']")
+	ut.AssertNil(err)
+	ut.AssertNil(f.Set("q", "surf & go"))
+	u, err := f.PreviewURL()
+	ut.AssertNil(err)
+	ut.AssertEquals(ts.URL+"/search?lang=en&page=1&q=surf+%26+go&submit=Search", u.String())
+	err = f.Submit()
+	ut.AssertNil(err)
+	ut.AssertEquals([]string{"/search?lang=en&page=1&q=surf+%26+go&submit=Search"}, requested)
+
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	f, err = bow.Form("form[name='login']")
+	ut.AssertNil(err)
+	u, err = f.PreviewURL()
+	ut.AssertNil(err)
+	ut.AssertEquals(ts.URL+"/login", u.String())
+}
+
+func TestBrowserFormSubmitButton(t *testing.T) {
+	ut.Run(t)
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, htmlFormButtons)
+			return
+		}
+		r.ParseForm()
+		requested = append(requested, r.URL.Path+"?"+r.PostForm.Encode())
+		fmt.Fprint(w, "ok")
+	}))
+	defer ts.Close()
+
+	bow := &Browser{}
+	bow.headers = make(http.Header, 10)
+	bow.history = jar.NewMemoryHistory()
+
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	f, err := bow.Form("form[name='only']")
+	ut.AssertNil(err)
+	u, err := f.PreviewURL()
+	ut.AssertNil(err)
+	ut.AssertEquals(ts.URL+"/b", u.String())
+	ut.AssertNil(f.Submit())
+
+	ut.AssertNil(bow.Open(ts.URL))
+	f, err = bow.Form("form[name='only']")
+	ut.AssertNil(err)
+	ut.AssertNil(f.SubmitContext(context.Background()))
+
+	ut.AssertNil(bow.Open(ts.URL))
+	f, err = bow.Form("form[name='mixed']")
+	ut.AssertNil(err)
+	ut.AssertNil(f.Submit())
+	ut.AssertEquals([]string{"/b?go=Go", "/b?go=Go", "/first?first=1"}, requested)
+}
+
+var htmlFormCheckboxes = `<!doctype html>
+<html>
+	<head>
+		<title>Checkboxes</title>
+	</head>
+	<body>
+		<form method="post" action="/">
+			<input type="checkbox" name="lang" value="go" checked />
+			<input type="checkbox" name="lang" value="python" />
+			<input type="checkbox" name="lang" value="rust" checked />
+			<input type="checkbox" name="newsletter" />
+			<input type="submit" name="submit" value="Save" />
+		</form>
+	</body>
+</html>
+`
+
+var htmlFormLogin = `<!doctype html>
+<html>
+	<head>
+		<title>Login</title>
+	</head>
+	<body>
+		<form method="post" action="/login" name="login">
+			<input type="text" name="username" value="" />
+			<input type="password" name="password" value="" />
+			<input type="hidden" name="token" value="abc" />
+			<input type="submit" name="login" value="Login" />
+		</form>
+	</body>
+</html>
+`
+
+var htmlFormUntyped = `<!doctype html>
+<html>
+	<head>
+		<title>Search</title>
+	</head>
+	<body>
+		<form action="/search" name="search">
+			<input name="q" />
+			<input name="lang" value="en" />
+		</form>
+	</body>
+</html>
+`
+
+var htmlFormAttributes = `<!doctype html>
+<html>
+	<head>
+		<title>Form Attributes</title>
+	</head>
+	<body>
+		<form method="post" action="submit" name="explicit">
+			<input type="text" name="age" value="" />
+		</form>
+		<form name="default">
+			<input type="text" name="age" value="" />
+		</form>
+		<form action="http://[::1" name="invalid">
+			<input type="text" name="age" value="" />
+		</form>
+	</body>
+</html>
+`
+
+var htmlFormOverrides = `<!doctype html>
+<html>
+	<head>
+		<title>Form Overrides</title>
+	</head>
+	<body>
+		<form method="post" action="/save">
+			<input type="text" name="age" value="" />
+			<input type="submit" name="save" value="saved" />
+			<button type="submit" name="preview" value="previewed" formaction="preview" formmethod="get">Preview</button>
+		</form>
+	</body>
+</html>
+`
+
+var htmlFormJSON = `<!doctype html>
+<html>
+	<head>
+		<title>Echo Form</title>
+	</head>
+	<body>
+		<form method="get" action="/json" name="json">
+			<input type="text" name="age" value="" />
+			<input type="hidden" name="tags" value="go" />
+			<input type="hidden" name="tags" value="surf" />
+			<input type="submit" name="submit" value="submitted" />
+		</form>
+	</body>
+</html>
+`
+
 var htmlForm = `<!doctype html>
 <html>
 	<head>
@@ -56,3 +642,85 @@ var htmlForm = `<!doctype html>
 	</body>
 </html>
 `
+
+var htmlFormUpload = `<!doctype html>
+<html>
+	<head>
+		<title>Upload</title>
+	</head>
+	<body>
+		<form method="post" action="/upload" name="upload" enctype="multipart/form-data">
+			<input type="text" name="title" value="" />
+			<input type="file" name="avatar" />
+			<input type="file" name="resume" />
+			<input type="file" />
+			<input type="submit" name="upload" value="Upload" />
+		</form>
+		<form method="post" action="/login" name="login">
+			<input type="text" name="username" value="" />
+		</form>
+	</body>
+</html>
+`
+
+var htmlFormRepeated = `<!doctype html>
+<html>
+	<head>
+		<title>Repeated</title>
+	</head>
+	<body>
+		<form method="get" action="/search">
+			<input type="checkbox" name="lang" value="go" checked />
+			<input type="checkbox" name="lang" value="python" />
+			<input type="checkbox" name="lang" value="rust" checked />
+			<input type="hidden" name="tag" value="a" />
+			<input type="hidden" name="tag" value="b" />
+			<select name="topic" multiple>
+				<option value="web" selected>Web</option>
+				<option value="db">Databases</option>
+				<option selected>cli</option>
+			</select>
+			<select name="size">
+				<option>m</option>
+				<option>l</option>
+			</select>
+		</form>
+	</body>
+</html>
+`
+
+var htmlFormPreview = `<!doctype html>
+<html>
+	<head>
+		<title>Preview</title>
+	</head>
+	<body>
+		<form method="get" action="search?page=1" name="search">
+			<input type="text" name="q" value="" />
+			<input type="hidden" name="lang" value="en" />
+			<input type="submit" name="submit" value="Search" />
+			<input type="submit" name="lucky" value="Lucky" />
+		</form>
+		<form method="post" action="/login" name="login">
+			<input type="text" name="username" value="" />
+		</form>
+	</body>
+</html>
+`
+
+var htmlFormButtons = `<!doctype html>
+<html>
+	<head>
+		<title>Buttons</title>
+	</head>
+	<body>
+		<form method="post" action="/a" name="only">
+			<button type="submit" name="go" value="Go" formaction="/b">Go</button>
+		</form>
+		<form method="post" action="/a" name="mixed">
+			<button type="submit" name="first" value="1" formaction="/first">First</button>
+			<input type="submit" name="second" value="2" formaction="/second" />
+		</form>
+	</body>
+</html>
+`
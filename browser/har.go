@@ -0,0 +1,175 @@
+package browser
+
+import (
+	"encoding/json"
+	"github.com/headzoo/surf/agent"
+	"github.com/headzoo/surf/jar"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// harVersion is the version of the HAR format written by ExportHAR.
+const harVersion = "1.2"
+
+// harLog is the root of a HAR document.
+type harLog struct {
+	Log struct {
+		Version string     `json:"version"`
+		Creator harCreator `json:"creator"`
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+// harCreator is the application which created a HAR document.
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// harEntry is a request and response in a HAR document.
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+// harRequest is a request in a HAR document.
+type harRequest struct {
+	Method      string    `json:"method"`
+	URL         string    `json:"url"`
+	HTTPVersion string    `json:"httpVersion"`
+	Cookies     []harPair `json:"cookies"`
+	Headers     []harPair `json:"headers"`
+	QueryString []harPair `json:"queryString"`
+	HeadersSize int       `json:"headersSize"`
+	BodySize    int64     `json:"bodySize"`
+}
+
+// harResponse is a response in a HAR document.
+type harResponse struct {
+	Status      int        `json:"status"`
+	StatusText  string     `json:"statusText"`
+	HTTPVersion string     `json:"httpVersion"`
+	Cookies     []harPair  `json:"cookies"`
+	Headers     []harPair  `json:"headers"`
+	Content     harContent `json:"content"`
+	RedirectURL string     `json:"redirectURL"`
+	HeadersSize int        `json:"headersSize"`
+	BodySize    int64      `json:"bodySize"`
+}
+
+// harContent describes the body of a response in a HAR document.
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+}
+
+// harPair is a header, cookie or query string value in a HAR document.
+type harPair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harTimings are the times taken by each part of a request in a HAR document.
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// ExportHAR writes the history and the current page to w in the HAR format.
+//
+// The document has an entry for each page in the history, starting with the
+// oldest, followed by the current page. Header and body sizes which are not
+// known are written as -1, and the whole time taken by each request is given
+// as the wait time.
+func (bow *Browser) ExportHAR(w io.Writer) error {
+	bow.mu.RLock()
+	states := append(bow.historyStates(), bow.state)
+	bow.mu.RUnlock()
+
+	doc := harLog{}
+	doc.Log.Version = harVersion
+	doc.Log.Creator = harCreator{Name: agent.Name, Version: agent.Version}
+	doc.Log.Entries = make([]harEntry, 0, len(states))
+	for _, st := range states {
+		if st != nil && st.Request != nil && st.Response != nil {
+			doc.Log.Entries = append(doc.Log.Entries, newHarEntry(st))
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// newHarEntry returns a HAR entry for the given state.
+func newHarEntry(st *jar.State) harEntry {
+	req, resp := st.Request, st.Response
+	ms := float64(st.Duration) / float64(time.Millisecond)
+
+	entry := harEntry{
+		StartedDateTime: st.Time.Format("2006-01-02T15:04:05.000Z07:00"),
+		Time:            ms,
+		Timings:         harTimings{Wait: ms},
+	}
+	entry.Request = harRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     []harPair{},
+		Headers:     harHeaders(req.Header),
+		QueryString: []harPair{},
+		HeadersSize: -1,
+		BodySize:    req.ContentLength,
+	}
+	for _, c := range req.Cookies() {
+		entry.Request.Cookies = append(entry.Request.Cookies, harPair{c.Name, c.Value})
+	}
+	for name, vs := range req.URL.Query() {
+		for _, v := range vs {
+			entry.Request.QueryString = append(entry.Request.QueryString, harPair{name, v})
+		}
+	}
+	sort.Slice(entry.Request.QueryString, func(i, j int) bool {
+		return entry.Request.QueryString[i].Name < entry.Request.QueryString[j].Name
+	})
+
+	entry.Response = harResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Cookies:     []harPair{},
+		Headers:     harHeaders(resp.Header),
+		Content:     harContent{Size: resp.ContentLength, MimeType: resp.Header.Get("Content-Type")},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    resp.ContentLength,
+	}
+	for _, c := range resp.Cookies() {
+		entry.Response.Cookies = append(entry.Response.Cookies, harPair{c.Name, c.Value})
+	}
+
+	return entry
+}
+
+// harHeaders returns the given headers as HAR pairs, sorted by name.
+func harHeaders(h http.Header) []harPair {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]harPair, 0, len(names))
+	for _, name := range names {
+		for _, v := range h[name] {
+			pairs = append(pairs, harPair{name, v})
+		}
+	}
+	return pairs
+}
@@ -0,0 +1,91 @@
+package browser
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// LogLevel is the minimum level of the messages written by a StdLogger.
+type LogLevel int
+
+const (
+	// LevelDebug writes every message.
+	LevelDebug LogLevel = iota
+
+	// LevelInfo writes info and error messages.
+	LevelInfo
+
+	// LevelError only writes error messages.
+	LevelError
+)
+
+// Logger is a structured logger used by the browser.
+//
+// The keyvals are alternating keys and values, eg "url", u, "status", 200. The
+// *slog.Logger type from the standard library satisfies this interface.
+type Logger interface {
+	// Debug logs a message at the debug level.
+	Debug(msg string, keyvals ...interface{})
+
+	// Info logs a message at the info level.
+	Info(msg string, keyvals ...interface{})
+
+	// Error logs a message at the error level.
+	Error(msg string, keyvals ...interface{})
+}
+
+// StdLogger adapts a *log.Logger to the Logger interface.
+type StdLogger struct {
+	logger *log.Logger
+	level  LogLevel
+}
+
+// NewStdLogger creates and returns a *StdLogger type which writes every message.
+func NewStdLogger(l *log.Logger) *StdLogger {
+	return &StdLogger{logger: l}
+}
+
+// NewWriterLogger creates and returns a *StdLogger type which writes the
+// messages at or above the given level to w.
+func NewWriterLogger(w io.Writer, lev LogLevel) *StdLogger {
+	return &StdLogger{
+		logger: log.New(w, "", log.LstdFlags),
+		level:  lev,
+	}
+}
+
+// Debug logs a message at the debug level.
+func (l *StdLogger) Debug(msg string, keyvals ...interface{}) {
+	if l.level <= LevelDebug {
+		l.output("DEBUG", msg, keyvals)
+	}
+}
+
+// Info logs a message at the info level.
+func (l *StdLogger) Info(msg string, keyvals ...interface{}) {
+	if l.level <= LevelInfo {
+		l.output("INFO", msg, keyvals)
+	}
+}
+
+// Error logs a message at the error level.
+func (l *StdLogger) Error(msg string, keyvals ...interface{}) {
+	l.output("ERROR", msg, keyvals)
+}
+
+// output writes the message and fields to the logger, eg
+// "INFO Request complete url=http://localhost status=200".
+func (l *StdLogger) output(level, msg string, keyvals []interface{}) {
+	fields := make([]string, 0, len(keyvals)/2+2)
+	fields = append(fields, level, msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 < len(keyvals) {
+			fields = append(fields, fmt.Sprintf("%v=%v", keyvals[i], keyvals[i+1]))
+		} else {
+			fields = append(fields, fmt.Sprintf("%v=", keyvals[i]))
+		}
+	}
+	l.logger.Print(strings.Join(fields, " "))
+}
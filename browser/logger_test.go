@@ -0,0 +1,43 @@
+package browser
+
+import (
+	"bytes"
+	"github.com/headzoo/ut"
+	"log"
+	"testing"
+)
+
+func TestStdLogger(t *testing.T) {
+	ut.Run(t)
+
+	buff := &bytes.Buffer{}
+	l := NewStdLogger(log.New(buff, "", 0))
+	l.Info("Request complete", "url", "http://localhost", "status", 200)
+	ut.AssertEquals("INFO Request complete url=http://localhost status=200\n", buff.String())
+
+	buff.Reset()
+	l.Debug("Odd", "key")
+	ut.AssertEquals("DEBUG Odd key=\n", buff.String())
+
+	buff.Reset()
+	l.Error("Failed")
+	ut.AssertEquals("ERROR Failed\n", buff.String())
+}
+
+func TestWriterLogger(t *testing.T) {
+	ut.Run(t)
+
+	buff := &bytes.Buffer{}
+	l := NewWriterLogger(buff, LevelInfo)
+	l.Debug("Hidden")
+	ut.AssertEquals("", buff.String())
+	l.Info("Request complete", "status", 200)
+	ut.AssertContains("INFO Request complete status=200\n", buff.String())
+
+	buff.Reset()
+	l = NewWriterLogger(buff, LevelError)
+	l.Info("Hidden")
+	ut.AssertEquals("", buff.String())
+	l.Error("Failed")
+	ut.AssertContains("ERROR Failed\n", buff.String())
+}
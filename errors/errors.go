@@ -85,6 +85,33 @@ func NewPageNotLoaded(msg string, a ...interface{}) PageNotLoaded {
 	}
 }
 
+// PageNotParsed represents a failed attempt to operate on the document of a page
+// which was not parsed as HTML.
+type PageNotParsed struct {
+	error
+}
+
+// NewPageNotParsed creates and returns a PageNotParsed type.
+func NewPageNotParsed(msg string, a ...interface{}) PageNotParsed {
+	msg = fmt.Sprintf("Page Not Parsed: "+msg, a...)
+	return PageNotParsed{
+		error: errors.New(msg),
+	}
+}
+
+// Timeout represents an operation which did not complete in the time allowed.
+type Timeout struct {
+	error
+}
+
+// NewTimeout creates and returns a Timeout type.
+func NewTimeout(msg string, a ...interface{}) Timeout {
+	msg = fmt.Sprintf("Timeout: "+msg, a...)
+	return Timeout{
+		error: errors.New(msg),
+	}
+}
+
 // ElementNotFound represents a failed attempt to operate on a non-existent page element.
 type ElementNotFound struct {
 	error
@@ -0,0 +1,2 @@
+// Package event contains a simple event dispatcher used by the browser.
+package event
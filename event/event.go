@@ -0,0 +1,211 @@
+package event
+
+import (
+	"sync"
+)
+
+// Event represents a browser event.
+type Event int
+
+const (
+	// PreRequest is dispatched before a request is sent.
+	//
+	// The handler args are the *http.Request about to be sent. The handlers may
+	// read the request body, eg to log or sign the request, and the full body
+	// is still sent. A handler may replace the request by assigning a new
+	// *http.Request to args[0], which is passed to the later handlers and sent.
+	PreRequest Event = iota
+
+	// PostRequest is dispatched after a response has been received and parsed.
+	//
+	// The handler args are the *http.Request and the *http.Response.
+	PostRequest
+
+	// Redirect is dispatched before a redirect is followed.
+	//
+	// The handler args are the *http.Request for the redirect destination, and
+	// the []*http.Request already made. Returning an error from the handler
+	// stops the redirect from being followed.
+	Redirect
+
+	// Error is dispatched when a request fails, including the requests made by
+	// Exists(), Stream(), DownloadUrl() and CheckLinks().
+	//
+	// The handler args are the error and the *http.Request which failed. Errors
+	// returned by the handlers are ignored.
+	Error
+
+	// SetCookie is dispatched when a response sets cookies, including the
+	// responses to redirects.
+	//
+	// The handler args are the []*http.Cookie set by the response, and the
+	// *http.Response. Errors returned by the handlers are ignored.
+	SetCookie
+
+	// Back is dispatched after the browser goes back to the previous page.
+	//
+	// The handler args are the *jar.State of the previous page. Errors returned
+	// by the handlers are ignored.
+	Back
+
+	// Forward is dispatched after the browser goes forward to the next page.
+	//
+	// The handler args are the *jar.State of the next page. Errors returned by
+	// the handlers are ignored.
+	Forward
+)
+
+// Handler handles a dispatched event.
+//
+// Every handler bound to an event is called with the same args slice, so a
+// handler may replace an arg by assigning to it. The replacement is passed to
+// the later handlers, and is seen by the code which dispatched the event when
+// it passed its own slice to Do(), eg Do(e, args...).
+type Handler interface {
+	// Handle is called when the event is dispatched.
+	Handle(e Event, args ...interface{}) error
+}
+
+// HandlerFunc is a function which implements Handler.
+type HandlerFunc func(e Event, args ...interface{}) error
+
+// Handle calls the function.
+func (h HandlerFunc) Handle(e Event, args ...interface{}) error {
+	return h(e, args...)
+}
+
+// Eventable represents a type which dispatches events to bound handlers.
+type Eventable interface {
+	// On binds a handler to the given event.
+	On(e Event, h Handler)
+
+	// OnFunc binds a handler function to the given event.
+	OnFunc(e Event, h HandlerFunc)
+
+	// Once binds a handler function to the given event which is unbound after
+	// it has been called once.
+	Once(e Event, h HandlerFunc)
+
+	// Off unbinds all the handlers for the given event.
+	Off(e Event)
+
+	// Do dispatches the given event to the bound handlers.
+	Do(e Event, args ...interface{}) error
+}
+
+// binding is a handler bound to an event.
+type binding struct {
+	handler Handler
+	once    bool
+}
+
+// Dispatcher is the default implementation of Eventable.
+//
+// The zero value is ready to use. Handlers may be bound, unbound and
+// dispatched from multiple goroutines.
+type Dispatcher struct {
+	handlers map[Event][]*binding
+	mu       sync.RWMutex
+}
+
+// NewDispatcher creates and returns a new *Dispatcher type.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{}
+}
+
+// On binds a handler to the given event.
+func (d *Dispatcher) On(e Event, h Handler) {
+	d.bind(e, &binding{handler: h})
+}
+
+// OnFunc binds a handler function to the given event.
+func (d *Dispatcher) OnFunc(e Event, h HandlerFunc) {
+	d.On(e, h)
+}
+
+// Once binds a handler function to the given event which is unbound after
+// it has been called once.
+func (d *Dispatcher) Once(e Event, h HandlerFunc) {
+	d.bind(e, &binding{handler: h, once: true})
+}
+
+// Off unbinds all the handlers for the given event.
+func (d *Dispatcher) Off(e Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.handlers, e)
+}
+
+// Do dispatches the given event to the bound handlers.
+//
+// Handlers are called in the order they were bound. Dispatching stops at the
+// first handler that returns an error, and the error is returned.
+//
+// The handlers bound when Do() is called are used, and no lock is held while
+// they run, so handlers may bind and unbind handlers or dispatch events.
+func (d *Dispatcher) Do(e Event, args ...interface{}) error {
+	d.mu.RLock()
+	bindings := d.handlers[e]
+	d.mu.RUnlock()
+	for _, b := range bindings {
+		if b.once && !d.unbind(e, b) {
+			continue
+		}
+		if err := b.handler.Handle(e, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CopyTo binds the handlers of the dispatcher to dst, after any handlers
+// already bound to dst.
+//
+// Handlers bound with Once() are copied too, and are called once by each
+// dispatcher.
+func (d *Dispatcher) CopyTo(dst *Dispatcher) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for e, bindings := range d.handlers {
+		for _, b := range bindings {
+			dst.bind(e, &binding{handler: b.handler, once: b.once})
+		}
+	}
+}
+
+// bind adds the binding to the handlers for the given event.
+func (d *Dispatcher) bind(e Event, b *binding) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.handlers == nil {
+		d.handlers = make(map[Event][]*binding)
+	}
+	d.handlers[e] = append(d.handlers[e], b)
+}
+
+// unbind removes the binding from the handlers for the given event, and
+// returns whether it was bound.
+//
+// A new slice is created so the removal is safe while Do() is ranging over
+// the current one. Only one of the goroutines dispatching an event at the same
+// time removes a binding, which is how a handler bound with Once() is called
+// exactly once.
+func (d *Dispatcher) unbind(e Event, b *binding) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	found := false
+	bindings := make([]*binding, 0, len(d.handlers[e]))
+	for _, bb := range d.handlers[e] {
+		if bb != b {
+			bindings = append(bindings, bb)
+		} else {
+			found = true
+		}
+	}
+	if len(bindings) == 0 {
+		delete(d.handlers, e)
+	} else {
+		d.handlers[e] = bindings
+	}
+	return found
+}
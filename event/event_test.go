@@ -0,0 +1,190 @@
+package event
+
+import (
+	"errors"
+	"github.com/headzoo/ut"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDispatcher(t *testing.T) {
+	ut.Run(t)
+
+	d := NewDispatcher()
+	calls := make([]string, 0, 2)
+	d.OnFunc(PreRequest, func(e Event, args ...interface{}) error {
+		ut.AssertEquals(PreRequest, e)
+		calls = append(calls, args[0].(string))
+		return nil
+	})
+	d.OnFunc(PreRequest, func(_ Event, args ...interface{}) error {
+		calls = append(calls, args[0].(string)+"2")
+		return nil
+	})
+
+	err := d.Do(PreRequest, "first")
+	ut.AssertNil(err)
+	ut.AssertEquals([]string{"first", "first2"}, calls)
+
+	err = d.Do(PostRequest, "none")
+	ut.AssertNil(err)
+	ut.AssertEquals(2, len(calls))
+
+	d.OnFunc(Redirect, func(_ Event, _ ...interface{}) error {
+		return errors.New("stop")
+	})
+	d.OnFunc(Redirect, func(_ Event, _ ...interface{}) error {
+		calls = append(calls, "unreachable")
+		return nil
+	})
+	err = d.Do(Redirect)
+	ut.AssertNotNil(err)
+	ut.AssertEquals(2, len(calls))
+}
+
+func TestDispatcherZeroValue(t *testing.T) {
+	ut.Run(t)
+
+	var d Dispatcher
+	err := d.Do(PreRequest)
+	ut.AssertNil(err)
+}
+
+func TestDispatcherOff(t *testing.T) {
+	ut.Run(t)
+
+	d := NewDispatcher()
+	calls := 0
+	d.OnFunc(PreRequest, func(_ Event, _ ...interface{}) error {
+		calls++
+		return nil
+	})
+	d.OnFunc(PostRequest, func(_ Event, _ ...interface{}) error {
+		calls++
+		return nil
+	})
+
+	d.Do(PreRequest)
+	ut.AssertEquals(1, calls)
+	d.Off(PreRequest)
+	d.Do(PreRequest)
+	ut.AssertEquals(1, calls)
+	d.Do(PostRequest)
+	ut.AssertEquals(2, calls)
+
+	var z Dispatcher
+	z.Off(PreRequest)
+}
+
+func TestDispatcherOnce(t *testing.T) {
+	ut.Run(t)
+
+	d := NewDispatcher()
+	once, always := 0, 0
+	d.Once(PreRequest, func(_ Event, _ ...interface{}) error {
+		once++
+		return nil
+	})
+	d.OnFunc(PreRequest, func(_ Event, _ ...interface{}) error {
+		always++
+		return nil
+	})
+
+	d.Do(PreRequest)
+	d.Do(PreRequest)
+	ut.AssertEquals(1, once)
+	ut.AssertEquals(2, always)
+}
+
+func TestDispatcherConcurrent(t *testing.T) {
+	ut.Run(t)
+
+	d := NewDispatcher()
+	var calls, once int32
+	d.Once(PreRequest, func(_ Event, _ ...interface{}) error {
+		atomic.AddInt32(&once, 1)
+		return nil
+	})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			d.OnFunc(PreRequest, func(_ Event, _ ...interface{}) error {
+				atomic.AddInt32(&calls, 1)
+				return nil
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			d.Do(PreRequest)
+			d.Do(PostRequest)
+		}()
+	}
+	wg.Wait()
+
+	// Handlers may dispatch events and bind handlers while they run.
+	d.OnFunc(PostRequest, func(_ Event, _ ...interface{}) error {
+		d.OnFunc(Redirect, func(_ Event, _ ...interface{}) error {
+			return nil
+		})
+		return d.Do(PreRequest)
+	})
+	ut.AssertNil(d.Do(PostRequest))
+	d.Off(Redirect)
+
+	ut.AssertEquals(int32(1), atomic.LoadInt32(&once))
+	before := atomic.LoadInt32(&calls)
+	d.Do(PreRequest)
+	ut.AssertEquals(before+10, atomic.LoadInt32(&calls))
+}
+
+func TestDispatcherReplaceArgs(t *testing.T) {
+	ut.Run(t)
+
+	d := NewDispatcher()
+	d.OnFunc(PreRequest, func(_ Event, args ...interface{}) error {
+		args[0] = args[0].(string) + " signed"
+		return nil
+	})
+	seen := ""
+	d.OnFunc(PreRequest, func(_ Event, args ...interface{}) error {
+		seen = args[0].(string)
+		return nil
+	})
+
+	args := []interface{}{"request"}
+	ut.AssertNil(d.Do(PreRequest, args...))
+	ut.AssertEquals("request signed", seen)
+	ut.AssertEquals("request signed", args[0])
+}
+
+func TestDispatcherCopyTo(t *testing.T) {
+	ut.Run(t)
+
+	d := NewDispatcher()
+	calls := 0
+	d.OnFunc(PreRequest, func(_ Event, _ ...interface{}) error {
+		calls++
+		return nil
+	})
+	d.Once(PostRequest, func(_ Event, _ ...interface{}) error {
+		calls++
+		return nil
+	})
+
+	var c Dispatcher
+	d.CopyTo(&c)
+	ut.AssertNil(c.Do(PreRequest))
+	ut.AssertNil(c.Do(PostRequest))
+	ut.AssertNil(c.Do(PostRequest))
+	ut.AssertEquals(2, calls)
+
+	ut.AssertNil(d.Do(PostRequest))
+	ut.AssertEquals(3, calls)
+	c.Off(PreRequest)
+	ut.AssertNil(d.Do(PreRequest))
+	ut.AssertEquals(4, calls)
+}
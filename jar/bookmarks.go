@@ -6,6 +6,7 @@ import (
 	"github.com/headzoo/surf/util"
 	"io/ioutil"
 	"os"
+	"time"
 )
 
 // initialBookmarksCapacity is the initial capacity for the bookmarks map.
@@ -14,6 +15,21 @@ const initialBookmarksCapacity = 20
 // BookmarksMap stores bookmarks.
 type BookmarksMap map[string]string
 
+// Bookmark is a saved URL and its metadata.
+type Bookmark struct {
+	// URL is the bookmarked URL.
+	URL string `json:"url"`
+
+	// Title is the title of the bookmarked page.
+	Title string `json:"title,omitempty"`
+
+	// Tags are used to group bookmarks.
+	Tags []string `json:"tags,omitempty"`
+
+	// Created is when the bookmark was saved.
+	Created time.Time `json:"created"`
+}
+
 // BookmarksJar is a container for storage and retrieval of bookmarks.
 type BookmarksJar interface {
 	// Save saves a bookmark with the given name.
@@ -32,15 +48,27 @@ type BookmarksJar interface {
 	All() BookmarksMap
 }
 
-// MemoryBookmarks is an in-memory implementation of BookmarksJar.
+// BookmarksMetadataJar is a bookmarks jar which also stores the metadata of
+// each bookmark.
+type BookmarksMetadataJar interface {
+	BookmarksJar
+
+	// SaveBookmark saves a bookmark and its metadata with the given name.
+	SaveBookmark(name string, b Bookmark) error
+
+	// ReadBookmark returns the bookmark and its metadata with the given name.
+	ReadBookmark(name string) (Bookmark, error)
+}
+
+// MemoryBookmarks is an in-memory implementation of BookmarksMetadataJar.
 type MemoryBookmarks struct {
-	bookmarks BookmarksMap
+	bookmarks map[string]Bookmark
 }
 
 // NewMemoryBookmarks creates and returns a new *BookmarkMemoryJar type.
 func NewMemoryBookmarks() *MemoryBookmarks {
 	return &MemoryBookmarks{
-		bookmarks: make(BookmarksMap, initialBookmarksCapacity),
+		bookmarks: make(map[string]Bookmark, initialBookmarksCapacity),
 	}
 }
 
@@ -49,11 +77,19 @@ func NewMemoryBookmarks() *MemoryBookmarks {
 // Returns an error when a bookmark with the given name already exists. Use the
 // Has() or Remove() methods first to avoid errors.
 func (b *MemoryBookmarks) Save(name, url string) error {
+	return b.SaveBookmark(name, Bookmark{URL: url})
+}
+
+// SaveBookmark saves a bookmark and its metadata with the given name.
+//
+// The Created time is set to the current time when it is zero. Returns an
+// error when a bookmark with the given name already exists.
+func (b *MemoryBookmarks) SaveBookmark(name string, bm Bookmark) error {
 	if b.Has(name) {
 		return errors.New(
 			"Bookmark with the name '%s' already exists.", name)
 	}
-	b.bookmarks[name] = url
+	b.bookmarks[name] = newBookmark(bm)
 	return nil
 }
 
@@ -62,11 +98,21 @@ func (b *MemoryBookmarks) Save(name, url string) error {
 // Returns an error when a bookmark does not exist with the given name. Use the
 // Has() method first to avoid errors.
 func (b *MemoryBookmarks) Read(name string) (string, error) {
+	bm, err := b.ReadBookmark(name)
+	return bm.URL, err
+}
+
+// ReadBookmark returns the bookmark and its metadata with the given name.
+//
+// Returns an error when a bookmark does not exist with the given name.
+func (b *MemoryBookmarks) ReadBookmark(name string) (Bookmark, error) {
 	if !b.Has(name) {
-		return "", errors.New(
+		return Bookmark{}, errors.New(
 			"A bookmark does not exist with the name '%s'.", name)
 	}
-	return b.bookmarks[name], nil
+	bm := b.bookmarks[name]
+	bm.Tags = append([]string(nil), bm.Tags...)
+	return bm, nil
 }
 
 // Remove deletes the bookmark with the given name.
@@ -90,31 +136,43 @@ func (b *MemoryBookmarks) Has(name string) bool {
 
 // All returns all of the bookmarks as a BookmarksMap.
 func (b *MemoryBookmarks) All() BookmarksMap {
-	return b.bookmarks
+	return bookmarksMap(b.bookmarks)
 }
 
-// FileBookmarks is an implementation of BookmarksJar that saves to a file.
+// FileBookmarks is an implementation of BookmarksMetadataJar that saves to a file.
 //
-// The bookmarks are saved as a JSON string.
+// The bookmarks are saved as a JSON string. Files saved by older versions,
+// which only contain the bookmark URLs, can still be read.
 type FileBookmarks struct {
-	bookmarks BookmarksMap
+	bookmarks map[string]Bookmark
 	file      string
 }
 
 // NewFileBookmarks creates and returns a new *FileBookmarks type.
 func NewFileBookmarks(file string) (*FileBookmarks, error) {
-	var bookmarks BookmarksMap = nil
-	if !util.FileExists(file) {
-		bookmarks = make(BookmarksMap, initialBookmarksCapacity)
-	} else {
+	bookmarks := make(map[string]Bookmark, initialBookmarksCapacity)
+	if util.FileExists(file) {
 		fin, err := ioutil.ReadFile(file)
 		if err != nil {
 			return nil, err
 		}
-		err = json.Unmarshal(fin, &bookmarks)
+		var raw map[string]json.RawMessage
+		err = json.Unmarshal(fin, &raw)
 		if err != nil {
 			return nil, err
 		}
+		for name, r := range raw {
+			var bm Bookmark
+			if len(r) > 0 && r[0] == '"' {
+				err = json.Unmarshal(r, &bm.URL)
+			} else {
+				err = json.Unmarshal(r, &bm)
+			}
+			if err != nil {
+				return nil, err
+			}
+			bookmarks[name] = bm
+		}
 	}
 
 	return &FileBookmarks{
@@ -128,11 +186,19 @@ func NewFileBookmarks(file string) (*FileBookmarks, error) {
 // Returns an error when a bookmark with the given name already exists. Use the
 // Has() or Remove() methods first to avoid errors.
 func (b *FileBookmarks) Save(name, url string) error {
+	return b.SaveBookmark(name, Bookmark{URL: url})
+}
+
+// SaveBookmark saves a bookmark and its metadata with the given name.
+//
+// The Created time is set to the current time when it is zero. Returns an
+// error when a bookmark with the given name already exists.
+func (b *FileBookmarks) SaveBookmark(name string, bm Bookmark) error {
 	if b.Has(name) {
 		return errors.New(
 			"Bookmark with the name '%s' already exists.", name)
 	}
-	b.bookmarks[name] = url
+	b.bookmarks[name] = newBookmark(bm)
 	return b.writeToFile()
 }
 
@@ -141,11 +207,21 @@ func (b *FileBookmarks) Save(name, url string) error {
 // Returns an error when a bookmark does not exist with the given name. Use the
 // Has() method first to avoid errors.
 func (b *FileBookmarks) Read(name string) (string, error) {
+	bm, err := b.ReadBookmark(name)
+	return bm.URL, err
+}
+
+// ReadBookmark returns the bookmark and its metadata with the given name.
+//
+// Returns an error when a bookmark does not exist with the given name.
+func (b *FileBookmarks) ReadBookmark(name string) (Bookmark, error) {
 	if !b.Has(name) {
-		return "", errors.New(
+		return Bookmark{}, errors.New(
 			"A bookmark does not exist with the name '%s'.", name)
 	}
-	return b.bookmarks[name], nil
+	bm := b.bookmarks[name]
+	bm.Tags = append([]string(nil), bm.Tags...)
+	return bm, nil
 }
 
 // Remove deletes the bookmark with the given name.
@@ -172,7 +248,7 @@ func (b *FileBookmarks) Has(name string) bool {
 
 // All returns all of the bookmarks as a BookmarksMap.
 func (b *FileBookmarks) All() BookmarksMap {
-	return b.bookmarks
+	return bookmarksMap(b.bookmarks)
 }
 
 // writeToFile writes the bookmarks to the file.
@@ -195,3 +271,22 @@ func (b *FileBookmarks) writeToFile() (err error) {
 
 	return err
 }
+
+// newBookmark returns a copy of the given bookmark, with the Created time set
+// to the current time when it is zero.
+func newBookmark(bm Bookmark) Bookmark {
+	bm.Tags = append([]string(nil), bm.Tags...)
+	if bm.Created.IsZero() {
+		bm.Created = time.Now()
+	}
+	return bm
+}
+
+// bookmarksMap returns the URLs of the given bookmarks as a BookmarksMap.
+func bookmarksMap(bookmarks map[string]Bookmark) BookmarksMap {
+	m := make(BookmarksMap, len(bookmarks))
+	for name, bm := range bookmarks {
+		m[name] = bm.URL
+	}
+	return m
+}
@@ -2,8 +2,11 @@ package jar
 
 import (
 	"github.com/headzoo/ut"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestMemoryBookmarks(t *testing.T) {
@@ -51,3 +54,71 @@ func assertBookmarks(b BookmarksJar) {
 	r = b.Has("test4")
 	ut.AssertFalse(r)
 }
+
+func TestBookmarkMetadata(t *testing.T) {
+	ut.Run(t)
+	dir, err := ioutil.TempDir("", "surf")
+	ut.AssertNil(err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "bookmarks.json")
+
+	fb, err := NewFileBookmarks(file)
+	ut.AssertNil(err)
+	for _, b := range []BookmarksMetadataJar{NewMemoryBookmarks(), fb} {
+		created := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+		err = b.SaveBookmark("docs", Bookmark{
+			URL:     "http://localhost/docs",
+			Title:   "Docs",
+			Tags:    []string{"go", "surf"},
+			Created: created,
+		})
+		ut.AssertNil(err)
+		err = b.Save("home", "http://localhost")
+		ut.AssertNil(err)
+
+		bm, err := b.ReadBookmark("docs")
+		ut.AssertNil(err)
+		ut.AssertEquals("http://localhost/docs", bm.URL)
+		ut.AssertEquals("Docs", bm.Title)
+		ut.AssertEquals([]string{"go", "surf"}, bm.Tags)
+		ut.AssertTrue(created.Equal(bm.Created))
+		u, err := b.Read("docs")
+		ut.AssertNil(err)
+		ut.AssertEquals("http://localhost/docs", u)
+
+		bm, err = b.ReadBookmark("home")
+		ut.AssertNil(err)
+		ut.AssertEquals("http://localhost", bm.URL)
+		ut.AssertFalse(bm.Created.IsZero())
+		ut.AssertEquals(BookmarksMap{
+			"docs": "http://localhost/docs",
+			"home": "http://localhost",
+		}, b.All())
+
+		_, err = b.ReadBookmark("missing")
+		ut.AssertNotNil(err)
+	}
+
+	fb, err = NewFileBookmarks(file)
+	ut.AssertNil(err)
+	bm, err := fb.ReadBookmark("docs")
+	ut.AssertNil(err)
+	ut.AssertEquals("Docs", bm.Title)
+	ut.AssertEquals([]string{"go", "surf"}, bm.Tags)
+}
+
+func TestFileBookmarksLegacy(t *testing.T) {
+	ut.Run(t)
+	dir, err := ioutil.TempDir("", "surf")
+	ut.AssertNil(err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "bookmarks.json")
+	err = ioutil.WriteFile(file, []byte(`{"home":"http://localhost"}`), 0600)
+	ut.AssertNil(err)
+
+	b, err := NewFileBookmarks(file)
+	ut.AssertNil(err)
+	u, err := b.Read("home")
+	ut.AssertNil(err)
+	ut.AssertEquals("http://localhost", u)
+}
@@ -0,0 +1,71 @@
+package jar
+
+import (
+	"net/http"
+	"sync"
+)
+
+// CacheEntry is a response stored in a cache.
+type CacheEntry struct {
+	// ETag is the ETag header of the response.
+	ETag string
+
+	// LastModified is the Last-Modified header of the response.
+	LastModified string
+
+	// StatusCode is the status code of the response.
+	StatusCode int
+
+	// Header are the response headers.
+	Header http.Header
+
+	// Body is the response body.
+	Body []byte
+}
+
+// Cache is a container for storage and retrieval of responses.
+type Cache interface {
+	// Get returns the entry for the given URL.
+	Get(url string) (*CacheEntry, bool)
+
+	// Set stores the entry for the given URL.
+	Set(url string, e *CacheEntry)
+
+	// Remove deletes the entry for the given URL.
+	Remove(url string)
+}
+
+// MemoryCache is an in-memory implementation of Cache.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]*CacheEntry
+}
+
+// NewMemoryCache creates and returns a new *MemoryCache type.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		entries: make(map[string]*CacheEntry),
+	}
+}
+
+// Get returns the entry for the given URL.
+func (c *MemoryCache) Get(url string) (*CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[url]
+	return e, ok
+}
+
+// Set stores the entry for the given URL.
+func (c *MemoryCache) Set(url string, e *CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[url] = e
+}
+
+// Remove deletes the entry for the given URL.
+func (c *MemoryCache) Remove(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, url)
+}
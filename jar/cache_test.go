@@ -0,0 +1,24 @@
+package jar
+
+import (
+	"github.com/headzoo/ut"
+	"testing"
+)
+
+func TestMemoryCache(t *testing.T) {
+	ut.Run(t)
+	cache := NewMemoryCache()
+
+	_, ok := cache.Get("http://example.com/")
+	ut.AssertFalse(ok)
+
+	entry := &CacheEntry{ETag: `"surf"`, Body: []byte("Hello, Surf!")}
+	cache.Set("http://example.com/", entry)
+	e, ok := cache.Get("http://example.com/")
+	ut.AssertTrue(ok)
+	ut.AssertEquals(entry, e)
+
+	cache.Remove("http://example.com/")
+	_, ok = cache.Get("http://example.com/")
+	ut.AssertFalse(ok)
+}
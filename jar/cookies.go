@@ -1,11 +1,418 @@
 package jar
 
-import "net/http/cookiejar"
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"github.com/headzoo/surf/errors"
+	"github.com/headzoo/surf/util"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
 
-// New returns a new cookie jar.
-func NewMemoryCookies() *cookiejar.Jar {
+// CookiesJar is a cookie jar which can export the cookies it stores.
+type CookiesJar interface {
+	http.CookieJar
+
+	// ExportCookies returns every cookie in the jar with all of its attributes.
+	ExportCookies() []*http.Cookie
+}
+
+// MemoryCookies is an in-memory implementation of CookiesJar.
+//
+// The cookies sent with requests are managed by a *cookiejar.Jar, which only
+// returns the name and value of each cookie. A copy of every cookie is kept
+// alongside so the Secure, HttpOnly, SameSite, Path, Domain and Expires
+// attributes can be exported.
+type MemoryCookies struct {
+	jar     *cookiejar.Jar
+	mu      sync.Mutex
+	cookies map[string]*http.Cookie
+}
+
+// NewMemoryCookies creates and returns a new *MemoryCookies type.
+func NewMemoryCookies() *MemoryCookies {
 	// cookiejar.New returns an error, but it's always nil. Maybe it's there
 	// for future use or to conform to an interface?
 	jar, _ := cookiejar.New(nil)
-	return jar
+	return &MemoryCookies{
+		jar:     jar,
+		cookies: make(map[string]*http.Cookie),
+	}
+}
+
+// SetCookies handles the receipt of the cookies in a reply for the given URL.
+func (c *MemoryCookies) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	c.jar.SetCookies(u, cookies)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	host := strings.ToLower(u.Hostname())
+	for _, cookie := range cookies {
+		cc := *cookie
+		cc.Raw = ""
+		cc.Unparsed = nil
+		domain := strings.TrimPrefix(strings.ToLower(cc.Domain), ".")
+		switch {
+		case domain == "":
+			cc.Domain = host
+		case host != domain && !strings.HasSuffix(host, "."+domain):
+			continue
+		case net.ParseIP(host) != nil:
+			cc.Domain = host
+		default:
+			cc.Domain = "." + domain
+		}
+		if cc.Path == "" || cc.Path[0] != '/' {
+			cc.Path = defaultCookiePath(u.Path)
+		}
+
+		key := cc.Domain + ";" + cc.Path + ";" + cc.Name
+		if cc.MaxAge > 0 {
+			cc.Expires = now.Add(time.Duration(cc.MaxAge) * time.Second)
+		}
+		if cc.MaxAge < 0 || (!cc.Expires.IsZero() && !cc.Expires.After(now)) {
+			delete(c.cookies, key)
+			continue
+		}
+		cc.MaxAge = 0
+		c.cookies[key] = &cc
+	}
+}
+
+// restore adds a cookie returned by ExportCookies() to the jar.
+//
+// A cookie with a Domain which does not begin with a "." is host-only, and is
+// set with an empty Domain by the host it was exported from.
+func (c *MemoryCookies) restore(cookie *http.Cookie) {
+	cc := *cookie
+	host := strings.TrimPrefix(cc.Domain, ".")
+	if host == cc.Domain {
+		cc.Domain = ""
+	}
+	u := &url.URL{Scheme: "http", Host: host, Path: cc.Path}
+	if cc.Secure {
+		u.Scheme = "https"
+	}
+	c.SetCookies(u, []*http.Cookie{&cc})
+}
+
+// Cookies returns the cookies to send in a request for the given URL.
+func (c *MemoryCookies) Cookies(u *url.URL) []*http.Cookie {
+	return c.jar.Cookies(u)
+}
+
+// ExportCookies returns every cookie in the jar with all of its attributes.
+//
+// Expired cookies are not returned. The Domain of host-only cookies is set to
+// the host which set the cookie, and the Domain of cookies which are also sent
+// to subdomains begins with a ".", such as ".example.com". The returned cookies
+// are copies, and changing them does not change the jar.
+func (c *MemoryCookies) ExportCookies() []*http.Cookie {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, len(c.cookies))
+	for key := range c.cookies {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	now := time.Now()
+	cookies := make([]*http.Cookie, 0, len(keys))
+	for _, key := range keys {
+		cookie := c.cookies[key]
+		if !cookie.Expires.IsZero() && !cookie.Expires.After(now) {
+			delete(c.cookies, key)
+			continue
+		}
+		cc := *cookie
+		cookies = append(cookies, &cc)
+	}
+	return cookies
+}
+
+// CopyCookies returns a new *MemoryCookies containing a copy of every cookie in the given jar.
+//
+// The cookies keep all of their attributes, and are sent to the same hosts and
+// paths as by the given jar. Changes to either jar do not change the other.
+func CopyCookies(cj CookiesJar) *MemoryCookies {
+	c := NewMemoryCookies()
+	for _, cookie := range cj.ExportCookies() {
+		c.restore(cookie)
+	}
+	return c
+}
+
+// FileCookies is an implementation of CookiesJar that saves to a file.
+//
+// The cookies are saved as a JSON string, which is encrypted using AES-GCM when
+// the jar is created with a key. Host-only cookies are saved with the host which
+// set them as the Domain, and are only sent to that host after being loaded.
+type FileCookies struct {
+	*MemoryCookies
+	file string
+	key  []byte
+	mu   sync.Mutex
+
+	// err is the error from the last save made by SetCookies.
+	err error
+}
+
+// NewFileCookies creates and returns a new *FileCookies type.
+//
+// The cookies are loaded from the file when it exists.
+func NewFileCookies(file string) (*FileCookies, error) {
+	return NewEncryptedFileCookies(file, nil)
+}
+
+// NewEncryptedFileCookies creates and returns a new *FileCookies type which
+// encrypts the file with the given key.
+//
+// The key must be 16, 24 or 32 bytes long to use AES-128, AES-192 or AES-256.
+// Returns an error when the file exists and can't be decrypted with the key.
+// The file is not encrypted when the key is nil.
+func NewEncryptedFileCookies(file string, key []byte) (*FileCookies, error) {
+	if key != nil {
+		if _, err := aes.NewCipher(key); err != nil {
+			return nil, err
+		}
+	}
+	c := &FileCookies{
+		MemoryCookies: NewMemoryCookies(),
+		file:          file,
+		key:           key,
+	}
+	if !util.FileExists(file) {
+		return c, nil
+	}
+
+	fin, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if key != nil {
+		fin, err = c.decrypt(fin)
+		if err != nil {
+			return nil, errors.New(
+				"Cannot decrypt the cookies in '%s'. The key may be wrong.", file)
+		}
+	}
+	var cookies []*http.Cookie
+	err = json.Unmarshal(fin, &cookies)
+	if err != nil {
+		return nil, err
+	}
+	for _, cookie := range cookies {
+		c.MemoryCookies.restore(cookie)
+	}
+
+	return c, nil
+}
+
+// SetCookies handles the receipt of the cookies in a reply for the given URL.
+//
+// Every call rewrites the file with all of the cookies in the jar. Use Err()
+// to check for an error writing the file.
+func (c *FileCookies) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	c.MemoryCookies.SetCookies(u, cookies)
+	err := c.Save()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+}
+
+// Err returns the error from writing the file the last time SetCookies was
+// called, or nil when it was written.
+func (c *FileCookies) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Save writes the cookies to the file.
+func (c *FileCookies) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	j, err := json.Marshal(c.ExportCookies())
+	if err != nil {
+		return err
+	}
+	if c.key != nil {
+		j, err = c.encrypt(j)
+		if err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(c.file, j, 0600)
+}
+
+// encrypt returns the given data encrypted with the key, prefixed by the nonce.
+func (c *FileCookies) encrypt(data []byte) ([]byte, error) {
+	gcm, err := c.gcm()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// decrypt returns the given data decrypted with the key.
+func (c *FileCookies) decrypt(data []byte) ([]byte, error) {
+	gcm, err := c.gcm()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("The encrypted data is too short.")
+	}
+	n := gcm.NonceSize()
+	return gcm.Open(nil, data[:n], data[n:], nil)
+}
+
+// gcm returns the AES-GCM cipher for the key.
+func (c *FileCookies) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// netscapeHttpOnly prefixes the domain of HttpOnly cookies in a Netscape
+// cookies.txt file.
+const netscapeHttpOnly = "#HttpOnly_"
+
+// LoadNetscapeCookies creates and returns a new *MemoryCookies type containing
+// the cookies in the given Netscape cookies.txt file.
+//
+// The format is used by curl, wget and most browser extensions which export
+// cookies. Each line has the tab separated domain, include subdomains flag,
+// path, secure flag, expiry time, name and value of a cookie. A domain with the
+// "#HttpOnly_" prefix is an HttpOnly cookie, and other lines beginning with "#"
+// are comments. Expired cookies are skipped. Returns an error when a line is
+// not a valid cookie.
+func LoadNetscapeCookies(file string) (*MemoryCookies, error) {
+	fin, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fin.Close()
+
+	c := NewMemoryCookies()
+	scanner := bufio.NewScanner(fin)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, netscapeHttpOnly)
+		if httpOnly {
+			line = line[len(netscapeHttpOnly):]
+		}
+		if strings.TrimSpace(line) == "" || (!httpOnly && line[0] == '#') {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 {
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			return nil, errors.New(
+				"Invalid cookie on line %d of '%s'.", n, file)
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, errors.New(
+				"Invalid expiry time on line %d of '%s'.", n, file)
+		}
+
+		domain := strings.TrimPrefix(strings.ToLower(fields[0]), ".")
+		cookie := &http.Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		if strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = domain
+		}
+		if expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+		}
+		u := &url.URL{Scheme: "http", Host: domain, Path: cookie.Path}
+		if cookie.Secure {
+			u.Scheme = "https"
+		}
+		c.SetCookies(u, []*http.Cookie{cookie})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// SaveNetscapeCookies writes the given cookies to a Netscape cookies.txt file.
+//
+// Use the ExportCookies() method of a CookiesJar to get the cookies. A cookie
+// is written with the include subdomains flag when its Domain begins with a
+// ".", which is how ExportCookies() marks the cookies set with a Domain
+// attribute. Session cookies are written with an expiry time of 0.
+func SaveNetscapeCookies(file string, cookies []*http.Cookie) error {
+	buff := &bytes.Buffer{}
+	buff.WriteString("# Netscape HTTP Cookie File\n\n")
+	for _, cookie := range cookies {
+		domain := strings.ToLower(cookie.Domain)
+		subdomains := "FALSE"
+		if strings.HasPrefix(domain, ".") {
+			subdomains = "TRUE"
+		}
+		if cookie.HttpOnly {
+			domain = netscapeHttpOnly + domain
+		}
+		path := cookie.Path
+		if path == "" {
+			path = "/"
+		}
+		secure := "FALSE"
+		if cookie.Secure {
+			secure = "TRUE"
+		}
+		var expires int64
+		if !cookie.Expires.IsZero() {
+			expires = cookie.Expires.Unix()
+		}
+		fmt.Fprintf(buff, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, subdomains, path, secure, expires, cookie.Name, cookie.Value)
+	}
+	return ioutil.WriteFile(file, buff.Bytes(), 0600)
+}
+
+// defaultCookiePath returns the default cookie path for the given request path.
+func defaultCookiePath(p string) string {
+	if p == "" || p[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(p, "/")
+	if i == 0 {
+		return "/"
+	}
+	return p[:i]
 }
@@ -0,0 +1,217 @@
+package jar
+
+import (
+	"bytes"
+	"github.com/headzoo/ut"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMemoryCookies(t *testing.T) {
+	ut.Run(t)
+	cookies := NewMemoryCookies()
+	u, _ := url.Parse("https://www.example.com/account/login")
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	cookies.SetCookies(u, []*http.Cookie{
+		{
+			Name:     "session",
+			Value:    "surf",
+			Path:     "/account",
+			Domain:   ".example.com",
+			Expires:  expires,
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		},
+		{Name: "host", Value: "only"},
+		{Name: "other", Value: "domain", Domain: "example.org"},
+	})
+	ut.AssertEquals(2, len(cookies.Cookies(u)))
+
+	exported := cookies.ExportCookies()
+	ut.AssertEquals(2, len(exported))
+	c := exported[0]
+	ut.AssertEquals("session", c.Name)
+	ut.AssertEquals("surf", c.Value)
+	ut.AssertEquals("/account", c.Path)
+	ut.AssertEquals(".example.com", c.Domain)
+	ut.AssertTrue(c.Expires.Equal(expires))
+	ut.AssertTrue(c.Secure)
+	ut.AssertTrue(c.HttpOnly)
+	ut.AssertEquals(http.SameSiteStrictMode, c.SameSite)
+
+	c = exported[1]
+	ut.AssertEquals("host", c.Name)
+	ut.AssertEquals("/account", c.Path)
+	ut.AssertEquals("www.example.com", c.Domain)
+
+	cookies.SetCookies(u, []*http.Cookie{
+		{Name: "session", Path: "/account", Domain: "example.com", MaxAge: -1},
+	})
+	exported = cookies.ExportCookies()
+	ut.AssertEquals(1, len(exported))
+	ut.AssertEquals("host", exported[0].Name)
+
+	cookies.SetCookies(u, []*http.Cookie{
+		{Name: "shared", Value: "all", Domain: "example.com"},
+	})
+	copied := CopyCookies(cookies)
+	exported = copied.ExportCookies()
+	ut.AssertEquals(2, len(exported))
+	ut.AssertEquals(".example.com", exported[0].Domain)
+	ut.AssertEquals("www.example.com", exported[1].Domain)
+	sub, _ := url.Parse("https://api.www.example.com/account")
+	ut.AssertEquals(1, len(copied.Cookies(sub)))
+	ut.AssertEquals("shared", copied.Cookies(sub)[0].Name)
+}
+
+func TestEncryptedFileCookies(t *testing.T) {
+	ut.Run(t)
+	dir, err := ioutil.TempDir("", "surf")
+	ut.AssertNil(err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cookies")
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	cookies, err := NewEncryptedFileCookies(file, key)
+	ut.AssertNil(err)
+	u, _ := url.Parse("https://www.example.com/account/login")
+	cookies.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "surf", Secure: true, HttpOnly: true, MaxAge: 3600},
+	})
+	ut.AssertNil(cookies.Save())
+
+	data, err := ioutil.ReadFile(file)
+	ut.AssertNil(err)
+	ut.AssertFalse(bytes.Contains(data, []byte("session")))
+
+	cookies, err = NewEncryptedFileCookies(file, key)
+	ut.AssertNil(err)
+	exported := cookies.ExportCookies()
+	ut.AssertEquals(1, len(exported))
+	ut.AssertEquals("session", exported[0].Name)
+	ut.AssertEquals("surf", exported[0].Value)
+	ut.AssertTrue(exported[0].Secure)
+	ut.AssertTrue(exported[0].HttpOnly)
+	ut.AssertEquals(1, len(cookies.Cookies(u)))
+
+	_, err = NewEncryptedFileCookies(file, []byte("fedcba9876543210fedcba9876543210"))
+	ut.AssertNotNil(err)
+	ut.AssertContains("key may be wrong", err.Error())
+
+	_, err = NewEncryptedFileCookies(file, []byte("short"))
+	ut.AssertNotNil(err)
+}
+
+func TestFileCookies(t *testing.T) {
+	ut.Run(t)
+	dir, err := ioutil.TempDir("", "surf")
+	ut.AssertNil(err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cookies.json")
+
+	cookies, err := NewFileCookies(file)
+	ut.AssertNil(err)
+	u, _ := url.Parse("http://example.com/")
+	cookies.SetCookies(u, []*http.Cookie{{Name: "session", Value: "surf"}})
+	ut.AssertNil(cookies.Err())
+
+	cookies, err = NewFileCookies(file)
+	ut.AssertNil(err)
+	ut.AssertEquals(1, len(cookies.Cookies(u)))
+
+	cookies, err = NewFileCookies(filepath.Join(dir, "missing", "cookies.json"))
+	ut.AssertNil(err)
+	cookies.SetCookies(u, []*http.Cookie{{Name: "session", Value: "surf"}})
+	ut.AssertNotNil(cookies.Err())
+}
+
+func TestFileCookiesHostOnly(t *testing.T) {
+	ut.Run(t)
+	dir, err := ioutil.TempDir("", "surf")
+	ut.AssertNil(err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cookies")
+	key := []byte("0123456789abcdef")
+
+	cookies, err := NewEncryptedFileCookies(file, key)
+	ut.AssertNil(err)
+	u, _ := url.Parse("https://example.com/")
+	cookies.SetCookies(u, []*http.Cookie{
+		{Name: "sid", Value: "secret"},
+		{Name: "shared", Value: "all", Domain: "example.com"},
+	})
+
+	cookies, err = NewEncryptedFileCookies(file, key)
+	ut.AssertNil(err)
+	ut.AssertEquals(2, len(cookies.Cookies(u)))
+	sub, _ := url.Parse("https://evil.example.com/")
+	sent := cookies.Cookies(sub)
+	ut.AssertEquals(1, len(sent))
+	ut.AssertEquals("shared", sent[0].Name)
+}
+
+func TestNetscapeCookies(t *testing.T) {
+	ut.Run(t)
+	dir, err := ioutil.TempDir("", "surf")
+	ut.AssertNil(err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cookies.txt")
+
+	expires := time.Now().Add(time.Hour).Unix()
+	data := "# Netscape HTTP Cookie File\n\n" +
+		"example.com\tFALSE\t/\tFALSE\t0\tsession\tsurf\n" +
+		".example.com\tTRUE\t/\tFALSE\t" + strconv.FormatInt(expires, 10) + "\tshared\tall\n" +
+		"#HttpOnly_example.com\tFALSE\t/\tFALSE\t0\tprivate\tyes\n" +
+		"example.com\tFALSE\t/\tTRUE\t0\tsecure\tyes\n" +
+		"example.com\tFALSE\t/\tFALSE\t1\texpired\tyes\n"
+	ut.AssertNil(ioutil.WriteFile(file, []byte(data), 0600))
+
+	cookies, err := LoadNetscapeCookies(file)
+	ut.AssertNil(err)
+	u, _ := url.Parse("http://example.com/")
+	ut.AssertEquals(3, len(cookies.Cookies(u)))
+	u, _ = url.Parse("https://example.com/")
+	ut.AssertEquals(4, len(cookies.Cookies(u)))
+	u, _ = url.Parse("http://www.example.com/")
+	ut.AssertEquals(1, len(cookies.Cookies(u)))
+
+	out := filepath.Join(dir, "saved.txt")
+	ut.AssertNil(SaveNetscapeCookies(out, cookies.ExportCookies()))
+	saved, err := ioutil.ReadFile(out)
+	ut.AssertNil(err)
+	ut.AssertContains("#HttpOnly_example.com\tFALSE", string(saved))
+	loaded, err := LoadNetscapeCookies(out)
+	ut.AssertNil(err)
+	ut.AssertEquals(len(cookies.ExportCookies()), len(loaded.ExportCookies()))
+
+	mem := NewMemoryCookies()
+	u, _ = url.Parse("http://example.com/")
+	mem.SetCookies(u, []*http.Cookie{
+		{Name: "shared", Value: "all", Domain: "example.com"},
+		{Name: "host", Value: "only"},
+	})
+	ut.AssertNil(SaveNetscapeCookies(out, mem.ExportCookies()))
+	saved, err = ioutil.ReadFile(out)
+	ut.AssertNil(err)
+	ut.AssertContains(".example.com\tTRUE\t/\tFALSE\t0\tshared\tall", string(saved))
+	ut.AssertContains("example.com\tFALSE\t/\tFALSE\t0\thost\tonly", string(saved))
+	loaded, err = LoadNetscapeCookies(out)
+	ut.AssertNil(err)
+	ut.AssertEquals(2, len(loaded.Cookies(u)))
+	u, _ = url.Parse("http://www.example.com/")
+	sent := loaded.Cookies(u)
+	ut.AssertEquals(1, len(sent))
+	ut.AssertEquals("shared", sent[0].Name)
+
+	ut.AssertNil(ioutil.WriteFile(file, []byte("example.com\tFALSE\n"), 0600))
+	_, err = LoadNetscapeCookies(file)
+	ut.AssertNotNil(err)
+}
@@ -3,6 +3,8 @@ package jar
 import (
 	"github.com/PuerkitoBio/goquery"
 	"net/http"
+	"net/url"
+	"time"
 )
 
 // State represents a point in time.
@@ -10,14 +12,39 @@ type State struct {
 	Request  *http.Request
 	Response *http.Response
 	Dom      *goquery.Document
+
+	// RequestedURL is the URL which was requested. It is not the same as the
+	// URL of Request when redirects were followed.
+	RequestedURL *url.URL
+
+	// Time is when the request was sent.
+	Time time.Time
+
+	// Duration is the time taken to receive the response.
+	Duration time.Duration
+
+	// Raw is the response body when it was not parsed into Dom.
+	Raw []byte
+
+	// Content is the response body when it does not have an HTML or XML
+	// content type, but was still parsed into Dom.
+	Content []byte
+
+	// BytesRead is the number of bytes read from the response body.
+	BytesRead int64
+
+	// FromCache is whether the response body came from the cache after the
+	// server responded with 304 Not Modified.
+	FromCache bool
 }
 
 // NewHistoryState creates and returns a new *State type.
 func NewHistoryState(req *http.Request, resp *http.Response, dom *goquery.Document) *State {
 	return &State{
-		Request:  req,
-		Response: resp,
-		Dom:      dom,
+		Request:      req,
+		Response:     resp,
+		Dom:          dom,
+		RequestedURL: req.URL,
 	}
 }
 
@@ -29,6 +56,22 @@ type History interface {
 	Top() *State
 }
 
+// HistoryLister is a History which can return all of its states.
+type HistoryLister interface {
+	History
+
+	// All returns the states in the history, starting with the oldest.
+	All() []*State
+}
+
+// HistoryClearer is a History which can remove all of its states at once.
+type HistoryClearer interface {
+	History
+
+	// Clear removes every state from the history.
+	Clear()
+}
+
 // Node holds stack values and points to the next element.
 type Node struct {
 	Value *State
@@ -77,3 +120,18 @@ func (his *MemoryHistory) Top() *State {
 	}
 	return his.top.Value
 }
+
+// Clear removes every State from the history.
+func (his *MemoryHistory) Clear() {
+	his.top = nil
+	his.size = 0
+}
+
+// All returns every State in the history, starting with the oldest.
+func (his *MemoryHistory) All() []*State {
+	states := make([]*State, his.size)
+	for i, node := his.size-1, his.top; node != nil; i, node = i-1, node.Next {
+		states[i] = node.Value
+	}
+	return states
+}
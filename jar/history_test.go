@@ -19,6 +19,8 @@ func TestMemoryHistory(t *testing.T) {
 	ut.AssertEquals(2, stack.Len())
 	ut.AssertEquals(page2, stack.Top())
 
+	ut.AssertEquals([]*State{page1, page2}, stack.All())
+
 	page := stack.Pop()
 	ut.AssertEquals(page, page2)
 	ut.AssertEquals(1, stack.Len())
@@ -27,4 +29,11 @@ func TestMemoryHistory(t *testing.T) {
 	page = stack.Pop()
 	ut.AssertEquals(page, page1)
 	ut.AssertEquals(0, stack.Len())
+
+	stack.Push(page1)
+	stack.Push(page2)
+	stack.Clear()
+	ut.AssertEquals(0, stack.Len())
+	ut.AssertNil(stack.Top())
+	ut.AssertEquals(0, len(stack.All()))
 }
@@ -2,13 +2,39 @@ package surf
 
 import (
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
 	"fmt"
+	"github.com/PuerkitoBio/goquery"
 	"github.com/headzoo/surf/browser"
+	"github.com/headzoo/surf/errors"
+	"github.com/headzoo/surf/event"
 	"github.com/headzoo/surf/jar"
+	"github.com/headzoo/surf/util"
 	"github.com/headzoo/ut"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestGet(t *testing.T) {
@@ -73,89 +99,3068 @@ func TestUserAgent(t *testing.T) {
 	ut.AssertEquals("Testing/1.0", bow.Body())
 }
 
+func TestUserAgentPool(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, req.UserAgent())
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetUserAgent("Testing/1.0")
+	pool := []string{"Testing/2.0", "Testing/3.0"}
+	bow.SetUserAgentPool(pool)
+	pool[0] = "Changed/1.0"
+	for _, ua := range []string{"Testing/2.0", "Testing/3.0", "Testing/2.0"} {
+		err := bow.Open(ts.URL)
+		ut.AssertNil(err)
+		ut.AssertEquals(ua, bow.Body())
+	}
+
+	bow.SetAttribute(browser.RandomUserAgent, true)
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertContains("Testing/", bow.Body())
+	ut.AssertTrue(bow.Body() != "Testing/1.0")
+
+	bow.SetUserAgentPool(nil)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("Testing/1.0", bow.Body())
+}
+
+func TestRetry(t *testing.T) {
+	ut.Run(t)
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests%3 != 0 {
+			http.Error(w, "Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprint(w, string(b))
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals(http.StatusServiceUnavailable, bow.StatusCode())
+	ut.AssertEquals(1, requests)
+
+	requests = 0
+	bow.SetRetry(3, time.Millisecond)
+	err = bow.Post(ts.URL, "text/plain", ioutil.NopCloser(bytes.NewBufferString("surf")))
+	ut.AssertNil(err)
+	ut.AssertEquals(http.StatusOK, bow.StatusCode())
+	ut.AssertEquals("surf", bow.Body())
+	ut.AssertEquals(3, requests)
+
+	requests = 0
+	bow.SetRetry(1, time.Millisecond)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals(http.StatusServiceUnavailable, bow.StatusCode())
+	ut.AssertEquals(2, requests)
+
+	requests = 0
+	bow.SetRetry(3, time.Millisecond)
+	bow.SetRetryStatusCodes([]int{http.StatusInternalServerError})
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals(1, requests)
+
+	requests = 0
+	bow.SetRetryStatusCodes([]int{http.StatusServiceUnavailable})
+	u, _ := url.Parse(ts.URL)
+	_, err = bow.DownloadUrl(u, ioutil.Discard)
+	ut.AssertNil(err)
+	ut.AssertEquals(3, requests)
+}
+
+func TestRateLimit(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetRateLimit(50 * time.Millisecond)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		err := bow.Open(ts.URL)
+		ut.AssertNil(err)
+	}
+	ut.AssertTrue(time.Since(start) >= 100*time.Millisecond)
+
+	ts2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage2)
+	}))
+	defer ts2.Close()
+
+	start = time.Now()
+	err := bow.Open(ts2.URL)
+	ut.AssertNil(err)
+	ut.AssertTrue(time.Since(start) < 50*time.Millisecond)
+
+	u, _ := url.Parse(ts.URL)
+	start = time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := bow.DownloadUrl(u, ioutil.Discard)
+		ut.AssertNil(err)
+	}
+	ut.AssertTrue(time.Since(start) >= 100*time.Millisecond)
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- bow.Open(ts.URL)
+		}()
+		go func() {
+			defer wg.Done()
+			bow.Title()
+			bow.Url()
+			bow.Back()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		ut.AssertNil(err)
+	}
+	ut.AssertEquals("Surf Page 1", bow.Title())
+}
+
+func TestClone(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page2" {
+			fmt.Fprint(w, htmlPage2)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: r.URL.Query().Get("session")})
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetUserAgent("Testing/1.0")
+	bow.AddRequestHeader("X-Testing", "Testing")
+	bow.SetAttribute(browser.SendReferer, false)
+	err := bow.Open(ts.URL + "/?session=one")
+	ut.AssertNil(err)
+
+	clone := bow.Clone()
+	ut.AssertEquals("Surf Page 1", clone.Title())
+	clone.Find("title").SetText("Clone")
+	ut.AssertEquals("Clone", clone.Title())
+	ut.AssertEquals("Surf Page 1", bow.Title())
+	ut.AssertEquals(1, len(clone.SiteCookies()))
+	ut.AssertEquals("one", clone.SiteCookies()[0].Value)
+
+	err = clone.Open(ts.URL + "/?session=two")
+	ut.AssertNil(err)
+	err = clone.Open(ts.URL + "/page2")
+	ut.AssertNil(err)
+	ut.AssertEquals("Surf Page 2", clone.Title())
+	ut.AssertEquals("Surf Page 1", bow.Title())
+	ut.AssertFalse(bow.Back())
+	ut.AssertEquals("one", bow.SiteCookies()[0].Value)
+
+	ut.AssertTrue(clone.Back())
+	ut.AssertEquals("two", clone.SiteCookies()[0].Value)
+
+	shared := bow.Clone()
+	shared.SetCookieJar(bow.CookieJar())
+	err = shared.Open(ts.URL + "/?session=three")
+	ut.AssertNil(err)
+	ut.AssertEquals("three", bow.SiteCookies()[0].Value)
+}
+
+func TestCloneCookies(t *testing.T) {
+	ut.Run(t)
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	bow := NewBrowser()
+	u, _ := url.Parse("https://www.example.com/account/login")
+	bow.CookieJar().SetCookies(u, []*http.Cookie{
+		{
+			Name:     "session",
+			Value:    "surf",
+			Path:     "/account",
+			Domain:   "example.com",
+			Expires:  expires,
+			Secure:   true,
+			HttpOnly: true,
+		},
+		{Name: "host", Value: "only"},
+	})
+	u, _ = url.Parse("http://example.org/")
+	bow.CookieJar().SetCookies(u, []*http.Cookie{
+		{Name: "other", Value: "site", Path: "/shop"},
+	})
+
+	clone := bow.Clone()
+	ut.AssertEquals(bow.ExportCookies(), clone.ExportCookies())
+	cookies := clone.ExportCookies()
+	ut.AssertEquals(3, len(cookies))
+	ut.AssertEquals(".example.com", cookies[0].Domain)
+	ut.AssertEquals("/account", cookies[0].Path)
+	ut.AssertTrue(cookies[0].Expires.Equal(expires))
+	ut.AssertTrue(cookies[0].Secure)
+	ut.AssertTrue(cookies[0].HttpOnly)
+
+	ut.AssertEquals(1, len(clone.CookiesForURL("https://api.example.com/account/profile")))
+	ut.AssertEquals(0, len(clone.CookiesForURL("http://api.example.com/account/profile")))
+	ut.AssertEquals(2, len(clone.CookiesForURL("https://www.example.com/account/profile")))
+	ut.AssertEquals(0, len(clone.CookiesForURL("http://example.org/")))
+	ut.AssertEquals(1, len(clone.CookiesForURL("http://example.org/shop/cart")))
+
+	u, _ = url.Parse("http://example.org/")
+	clone.CookieJar().SetCookies(u, []*http.Cookie{{Name: "clone", Value: "only"}})
+	ut.AssertEquals(3, len(bow.ExportCookies()))
+}
+
+func TestOpenWithOptions(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "temporary"})
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		if c, err := r.Cookie("session"); err == nil {
+			fmt.Fprint(w, c.Value)
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	cookies := jar.NewMemoryCookies()
+	err := bow.OpenWithOptions(ts.URL+"/login", browser.RequestOptions{CookieJar: cookies})
+	ut.AssertNil(err)
+	ut.AssertEquals("temporary", bow.Body())
+	ut.AssertEquals(0, len(bow.SiteCookies()))
+	ut.AssertEquals(1, len(cookies.Cookies(bow.Url())))
+
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("", bow.Body())
+}
+
+func TestExportCookies(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     "session",
+			Value:    "surf",
+			Path:     "/",
+			MaxAge:   3600,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	cookies := bow.ExportCookies()
+	ut.AssertEquals(1, len(cookies))
+	ut.AssertEquals("session", cookies[0].Name)
+	ut.AssertEquals("127.0.0.1", cookies[0].Domain)
+	ut.AssertTrue(cookies[0].HttpOnly)
+	ut.AssertEquals(http.SameSiteLaxMode, cookies[0].SameSite)
+	ut.AssertTrue(cookies[0].Expires.After(time.Now()))
+}
+
+func TestMiddleware(t *testing.T) {
+	ut.Run(t)
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, strings.Join(r.Header["X-Surf"], ","))
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.Use(
+		func(req *http.Request) error {
+			req.Header.Add("X-Surf", "first")
+			return nil
+		},
+		func(req *http.Request) error {
+			req.Header.Add("X-Surf", "second")
+			return nil
+		},
+	)
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("first,second", bow.Body())
+	ut.AssertEquals(1, requests)
+
+	called := false
+	bow.Use(
+		func(req *http.Request) error {
+			return errors.New("Request not signed.")
+		},
+		func(req *http.Request) error {
+			called = true
+			return nil
+		},
+	)
+	err = bow.Open(ts.URL)
+	ut.AssertNotNil(err)
+	ut.AssertEquals("Request not signed.", err.Error())
+	ut.AssertFalse(called)
+	ut.AssertEquals(1, requests)
+	ut.AssertEquals("first,second", bow.Body())
+}
+
+func TestLogger(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	logger := &captureLogger{}
+	bow := NewBrowser()
+	bow.SetLogger(logger)
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals(1, len(logger.entries))
+	entry := logger.entries[0]
+	ut.AssertEquals("info", entry.level)
+	ut.AssertEquals("GET", entry.fields["method"])
+	ut.AssertEquals(ts.URL, entry.fields["url"])
+	ut.AssertEquals(http.StatusOK, entry.fields["status"])
+	_, ok := entry.fields["duration"].(time.Duration)
+	ut.AssertTrue(ok)
+
+	ts.Close()
+	err = bow.Open(ts.URL)
+	ut.AssertNotNil(err)
+	ut.AssertEquals(2, len(logger.entries))
+	entry = logger.entries[1]
+	ut.AssertEquals("error", entry.level)
+	ut.AssertEquals(err, entry.fields["error"])
+}
+
+func TestLoggerMetaRefresh(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/refresh" {
+			fmt.Fprint(w, htmlRefresh)
+		} else {
+			fmt.Fprint(w, htmlPage1)
+		}
+	}))
+	defer ts.Close()
+
+	buff := &bytes.Buffer{}
+	bow := NewBrowser()
+	bow.SetLogger(browser.NewStdLogger(log.New(buff, "", 0)))
+	err := bow.Open(ts.URL + "/refresh")
+	ut.AssertNil(err)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	lines := strings.Split(strings.TrimSpace(buff.String()), "\n")
+	ut.AssertEquals(3, len(lines))
+	ut.AssertEquals(fmt.Sprintf("DEBUG Creating meta refresh timer url=%s/refresh seconds=10", ts.URL), lines[1])
+}
+
+func TestFollowMetaRefresh(t *testing.T) {
+	ut.Run(t)
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/redirect":
+			fmt.Fprint(w, `<html><head><meta http-equiv="refresh" content="0.01; URL='/page2'"></head></html>`)
+		case "/reload":
+			fmt.Fprint(w, `<html><head><meta http-equiv="refresh" content="0"></head></html>`)
+		default:
+			fmt.Fprint(w, htmlPage2)
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	_, err := bow.FollowMetaRefresh()
+	ut.AssertNotNil(err)
+
+	bow.SetAttribute(browser.MetaRefreshHandling, false)
+	err = bow.Open(ts.URL + "/redirect")
+	ut.AssertNil(err)
+	ok, err := bow.FollowMetaRefresh()
+	ut.AssertNil(err)
+	ut.AssertTrue(ok)
+	ut.AssertEquals(ts.URL+"/page2", bow.Url().String())
+	ut.AssertEquals("Surf Page 2", bow.Title())
+
+	ok, err = bow.FollowMetaRefresh()
+	ut.AssertNil(err)
+	ut.AssertFalse(ok)
+
+	requests = 0
+	err = bow.Open(ts.URL + "/reload")
+	ut.AssertNil(err)
+	ok, err = bow.FollowMetaRefresh()
+	ut.AssertNil(err)
+	ut.AssertTrue(ok)
+	ut.AssertEquals(ts.URL+"/reload", bow.Url().String())
+	ut.AssertEquals(2, requests)
+}
+
+func TestStopMetaRefresh(t *testing.T) {
+	ut.Run(t)
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path == "/refresh" {
+			fmt.Fprint(w, `<html><head><meta http-equiv="refresh" content="0.05"></head></html>`)
+		} else {
+			fmt.Fprint(w, htmlPage1)
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL + "/refresh")
+	ut.AssertNil(err)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	time.Sleep(150 * time.Millisecond)
+	ut.AssertEquals(int32(2), atomic.LoadInt32(&requests))
+	ut.AssertEquals("Surf Page 1", bow.Title())
+
+	err = bow.Open(ts.URL + "/refresh")
+	ut.AssertNil(err)
+	bow.StopMetaRefresh()
+	time.Sleep(150 * time.Millisecond)
+	ut.AssertEquals(int32(3), atomic.LoadInt32(&requests))
+}
+
+// captureLogger is a browser.Logger which records the log entries.
+type captureLogger struct {
+	entries []captureEntry
+}
+
+// captureEntry is a log entry recorded by captureLogger.
+type captureEntry struct {
+	level  string
+	msg    string
+	fields map[string]interface{}
+}
+
+func (l *captureLogger) Debug(msg string, keyvals ...interface{}) {
+	l.capture("debug", msg, keyvals)
+}
+
+func (l *captureLogger) Info(msg string, keyvals ...interface{}) {
+	l.capture("info", msg, keyvals)
+}
+
+func (l *captureLogger) Error(msg string, keyvals ...interface{}) {
+	l.capture("error", msg, keyvals)
+}
+
+func (l *captureLogger) capture(level, msg string, keyvals []interface{}) {
+	fields := make(map[string]interface{})
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		fields[keyvals[i].(string)] = keyvals[i+1]
+	}
+	l.entries = append(l.entries, captureEntry{level, msg, fields})
+}
+
+func TestOptions(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" {
+			w.Header().Set("Allow", "GET, post,OPTIONS")
+			return
+		}
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals(0, len(bow.AllowedMethods()))
+
+	h, err := bow.Options(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("GET, post,OPTIONS", h.Get("Allow"))
+	ut.AssertEquals([]string{"GET", "POST", "OPTIONS"}, bow.AllowedMethods())
+	ut.AssertEquals("", bow.Title())
+
+	ut.AssertTrue(bow.Back())
+	ut.AssertEquals("Surf Page 1", bow.Title())
+}
+
+func TestResponse(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-Testing", "Testing")
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetTransport(ts.Client().Transport)
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	resp := bow.Response()
+	ut.AssertNotNil(resp)
+	ut.AssertNotNil(resp.TLS)
+	ut.AssertEquals(http.StatusOK, resp.StatusCode)
+	ut.AssertEquals("Testing", resp.Header.Get("X-Testing"))
+}
+
+func TestContentType(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		fmt.Fprint(w, "{}")
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		header string
+		typ    string
+		html   bool
+		json   bool
+	}{
+		{"text/html; charset=utf-8", "text/html", true, false},
+		{"application/xhtml+xml", "application/xhtml+xml", true, false},
+		{"application/json; charset=utf-8", "application/json", false, true},
+		{"application/hal+json", "application/hal+json", false, true},
+		{"image/png", "image/png", false, false},
+		{"invalid;;", "", false, false},
+	}
+
+	bow := NewBrowser()
+	for _, test := range tests {
+		err := bow.OpenForm(ts.URL, url.Values{"type": {test.header}})
+		ut.AssertNil(err)
+		ut.AssertEquals(test.typ, bow.ContentType())
+		ut.AssertEquals(test.html, bow.IsHTML())
+		ut.AssertEquals(test.json, bow.IsJSON())
+	}
+}
+
 func TestHeaders(t *testing.T) {
 	ut.Run(t)
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		fmt.Fprint(w, req.Header.Get("X-Testing-1"))
-		fmt.Fprint(w, req.Header.Get("X-Testing-2"))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, req.Header.Get("X-Testing-1"))
+		fmt.Fprint(w, req.Header.Get("X-Testing-2"))
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.AddRequestHeader("X-Testing-1", "Testing-1")
+	bow.AddRequestHeader("X-Testing-2", "Testing-2")
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertContains("Testing-1", bow.Body())
+	ut.AssertContains("Testing-2", bow.Body())
+}
+
+func TestBookmarks(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bookmarks := jar.NewMemoryBookmarks()
+	bow := NewBrowser()
+	bow.SetBookmarksJar(bookmarks)
+
+	bookmarks.Save("test1", ts.URL)
+	bow.OpenBookmark("test1")
+	ut.AssertEquals("Surf Page 1", bow.Title())
+	ut.AssertContains("<p>Hello, Surf!</p>", bow.Body())
+
+	err := bow.Bookmark("test2")
+	ut.AssertNil(err)
+	bow.OpenBookmark("test2")
+	ut.AssertEquals("Surf Page 1", bow.Title())
+}
+
+// mapBookmarks is a jar.BookmarksJar which implements only the required methods.
+type mapBookmarks jar.BookmarksMap
+
+func (b mapBookmarks) Save(name, url string) error {
+	b[name] = url
+	return nil
+}
+
+func (b mapBookmarks) Read(name string) (string, error) {
+	return b[name], nil
+}
+
+func (b mapBookmarks) Remove(name string) bool {
+	_, ok := b[name]
+	delete(b, name)
+	return ok
+}
+
+func (b mapBookmarks) Has(name string) bool {
+	_, ok := b[name]
+	return ok
+}
+
+func (b mapBookmarks) All() jar.BookmarksMap {
+	return jar.BookmarksMap(b)
+}
+
+func TestBookmarksJar(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bookmarks := mapBookmarks{}
+	bow := NewBrowser()
+	bow.SetBookmarksJar(bookmarks)
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	err = bow.Bookmark("test")
+	ut.AssertNil(err)
+	ut.AssertEquals(ts.URL, bookmarks["test"])
+
+	err = bow.Clone().OpenBookmark("test")
+	ut.AssertNil(err)
+}
+
+func TestClick(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, htmlPage1)
+		} else if r.URL.Path == "/page2" {
+			fmt.Fprint(w, htmlPage1)
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	err = bow.Click("a:contains('click')")
+	ut.AssertNil(err)
+	ut.AssertContains("<p>Hello, Surf!</p>", bow.Body())
+}
+
+func TestLinks(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	links := bow.Links()
+	ut.AssertEquals(2, len(links))
+	ut.AssertEquals("", links[0].ID)
+	ut.AssertEquals(ts.URL+"/page2", links[0].URL.String())
+	ut.AssertEquals("click", links[0].Text)
+	ut.AssertEquals("page3", links[1].ID)
+	ut.AssertEquals(ts.URL+"/page3", links[1].URL.String())
+	ut.AssertEquals("no clicking", links[1].Text)
+}
+
+func TestImages(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	images := bow.Images()
+	ut.AssertEquals(2, len(images))
+	ut.AssertEquals("imgur-image", images[0].ID)
+	ut.AssertEquals("http://i.imgur.com/HW4bJtY.jpg", images[0].URL.String())
+	ut.AssertEquals("", images[0].Alt)
+	ut.AssertEquals("It's a...", images[0].Title)
+
+	ut.AssertEquals("", images[1].ID)
+	ut.AssertEquals(ts.URL+"/Cxagv.jpg", images[1].URL.String())
+	ut.AssertEquals("A picture", images[1].Alt)
+	ut.AssertEquals("", images[1].Title)
+
+	buff := &bytes.Buffer{}
+	l, err := images[0].Download(buff)
+	ut.AssertNil(err)
+	ut.AssertGreaterThan(0, buff.Len())
+	ut.AssertEquals(int(l), buff.Len())
+}
+
+func TestStylesheets(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	stylesheets := bow.Stylesheets()
+	ut.AssertEquals(2, len(stylesheets))
+	ut.AssertEquals("http://godoc.org/-/site.css", stylesheets[0].URL.String())
+	ut.AssertEquals("all", stylesheets[0].Media)
+	ut.AssertEquals("text/css", stylesheets[0].Type)
+
+	ut.AssertEquals(ts.URL+"/print.css", stylesheets[1].URL.String())
+	ut.AssertEquals("print", stylesheets[1].Media)
+	ut.AssertEquals("text/css", stylesheets[1].Type)
+
+	buff := &bytes.Buffer{}
+	l, err := stylesheets[0].Download(buff)
+	ut.AssertNil(err)
+	ut.AssertGreaterThan(0, buff.Len())
+	ut.AssertEquals(int(l), buff.Len())
+}
+
+func TestScripts(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	scripts := bow.Scripts()
+	ut.AssertEquals(2, len(scripts))
+	ut.AssertEquals("http://godoc.org/-/site.js", scripts[0].URL.String())
+	ut.AssertEquals("text/javascript", scripts[0].Type)
+
+	ut.AssertEquals(ts.URL+"/jquery.min.js", scripts[1].URL.String())
+	ut.AssertEquals("text/javascript", scripts[1].Type)
+
+	buff := &bytes.Buffer{}
+	l, err := scripts[0].Download(buff)
+	ut.AssertNil(err)
+	ut.AssertGreaterThan(0, buff.Len())
+	ut.AssertEquals(int(l), buff.Len())
+}
+
+func TestRedirectEvent(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/page2", http.StatusFound)
+		} else if r.URL.Path == "/page2" {
+			fmt.Fprint(w, htmlPage2)
+		} else {
+			fmt.Fprint(w, htmlPage1)
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	redirects := 0
+	bow.OnFunc(event.Redirect, func(_ event.Event, args ...interface{}) error {
+		redirects++
+		ut.AssertEquals("/page2", args[0].(*http.Request).URL.Path)
+		return nil
+	})
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals(1, redirects)
+	ut.AssertEquals("Surf Page 2", bow.Title())
+
+	bow = NewBrowser()
+	err = bow.Open(ts.URL + "/page1")
+	ut.AssertNil(err)
+	bow.OnFunc(event.Redirect, func(_ event.Event, args ...interface{}) error {
+		return errors.NewLocation("Vetoed '%s'.", args[0].(*http.Request).URL)
+	})
+	err = bow.Open(ts.URL)
+	ut.AssertNotNil(err)
+	ut.AssertEquals("Surf Page 1", bow.Title())
+}
+
+func TestStats(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/page2", http.StatusFound)
+		} else if r.URL.Path == "/page2" {
+			fmt.Fprint(w, htmlPage2)
+		} else {
+			fmt.Fprint(w, htmlPage1)
+		}
+	}))
+	defer ts.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	bow := NewBrowser()
+	ut.AssertEquals(browser.Stats{}, bow.Stats())
+	err := bow.Open(ts.URL + "/page1")
+	ut.AssertNil(err)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	err = bow.Open(closed.URL)
+	ut.AssertNotNil(err)
+
+	stats := bow.Stats()
+	ut.AssertEquals(int64(4), stats.Requests)
+	ut.AssertEquals(int64(len(htmlPage1)+len(htmlPage2)), stats.BytesRead)
+	ut.AssertEquals(int64(1), stats.Errors)
+	ut.AssertEquals(int64(1), stats.Redirects)
+
+	u, _ := url.Parse(ts.URL + "/page1")
+	_, err = bow.DownloadUrl(u, ioutil.Discard)
+	ut.AssertNil(err)
+	u, _ = url.Parse(closed.URL)
+	_, err = bow.DownloadUrl(u, ioutil.Discard)
+	ut.AssertNotNil(err)
+
+	stats = bow.Stats()
+	ut.AssertEquals(int64(6), stats.Requests)
+	ut.AssertEquals(int64(2*len(htmlPage1)+len(htmlPage2)), stats.BytesRead)
+	ut.AssertEquals(int64(2), stats.Errors)
+
+	_, err = bow.Exists(closed.URL)
+	ut.AssertNotNil(err)
+	_, _, err = bow.Stream(closed.URL)
+	ut.AssertNotNil(err)
+
+	body, _, err := bow.Stream(ts.URL + "/page1")
+	ut.AssertNil(err)
+	io.Copy(ioutil.Discard, body)
+	body.Close()
+
+	stats = bow.Stats()
+	ut.AssertEquals(int64(9), stats.Requests)
+	ut.AssertEquals(int64(3*len(htmlPage1)+len(htmlPage2)), stats.BytesRead)
+	ut.AssertEquals(int64(4), stats.Errors)
+
+	bow.Reset()
+	ut.AssertEquals(browser.Stats{}, bow.Stats())
+}
+
+func TestErrorEvent(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	u := ts.URL
+	ts.Close()
+
+	bow := NewBrowser()
+	var failed error
+	var req *http.Request
+	bow.OnFunc(event.Error, func(_ event.Event, args ...interface{}) error {
+		failed = args[0].(error)
+		req = args[1].(*http.Request)
+		return errors.New("Handler error.")
+	})
+	err := bow.Open(u)
+	ut.AssertNotNil(err)
+	ut.AssertEquals(err, failed)
+	ut.AssertEquals(u, req.URL.String())
+
+	failed, req = nil, nil
+	ok, err := bow.Exists(u + "/exists")
+	ut.AssertFalse(ok)
+	ut.AssertNotNil(err)
+	ut.AssertEquals(err, failed)
+	ut.AssertEquals(u+"/exists", req.URL.String())
+
+	failed, req = nil, nil
+	_, _, err = bow.Stream(u + "/stream")
+	ut.AssertNotNil(err)
+	ut.AssertEquals(err, failed)
+	ut.AssertEquals(u+"/stream", req.URL.String())
+}
+
+func TestDownloadImages(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "surf"})
+			fmt.Fprint(w, htmlImages)
+		case "/images/one.png", "/two.jpg":
+			c, err := r.Cookie("session")
+			if err != nil || c.Value != "surf" {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, r.URL.Path)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "surf")
+	ut.AssertNil(err)
+	defer os.RemoveAll(dir)
+
+	bow := NewBrowser()
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	n, err := bow.DownloadImages(dir)
+	ut.AssertNotNil(err)
+	ut.AssertEquals(2, n)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "one.png"))
+	ut.AssertNil(err)
+	ut.AssertEquals("/images/one.png", string(b))
+	b, err = ioutil.ReadFile(filepath.Join(dir, "two.jpg"))
+	ut.AssertNil(err)
+	ut.AssertEquals("/two.jpg", string(b))
+	ut.AssertFalse(util.FileExists(filepath.Join(dir, "missing.gif")))
+}
+
+func TestSavePage(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, htmlSavePage)
+		} else {
+			fmt.Fprint(w, r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "surf")
+	ut.AssertNil(err)
+	defer os.RemoveAll(dir)
+
+	bow := NewBrowser()
+	err = bow.SavePage(dir)
+	ut.AssertNotNil(err)
+
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	bow.SetAttribute(browser.SkipExternalAssets, true)
+	err = bow.SavePage(filepath.Join(dir, "page"))
+	ut.AssertNil(err)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "page", "index.html"))
+	ut.AssertNil(err)
+	saved := string(b)
+	ut.AssertContains(`href="style.css"`, saved)
+	ut.AssertContains(`src="app.js"`, saved)
+	ut.AssertContains(`src="logo.png"`, saved)
+	ut.AssertContains(`src="logo-1.png"`, saved)
+	ut.AssertContains(`src="http://external.invalid/lib.js"`, saved)
+	ut.AssertContains(`src="data:image/gif;base64,R0lGODlhAQABAAAAACw="`, saved)
+	ut.AssertEquals(2, strings.Count(saved, `src="logo.png"`))
+
+	expected := map[string]string{
+		"style.css":  "/css/style.css",
+		"app.js":     "/js/app.js",
+		"logo.png":   "/a/logo.png",
+		"logo-1.png": "/b/logo.png",
+	}
+	for name, content := range expected {
+		b, err = ioutil.ReadFile(filepath.Join(dir, "page", name))
+		ut.AssertNil(err)
+		ut.AssertEquals(content, string(b))
+	}
+
+	ut.AssertEquals(4, len(bow.Images()))
+	ut.AssertEquals(ts.URL+"/a/logo.png", bow.Images()[0].URL.String())
+}
+
+func TestAssetDownloadWith(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "surf"})
+			fmt.Fprint(w, htmlAssets)
+			return
+		}
+		c, err := r.Cookie("session")
+		if err != nil || c.Value != "surf" {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	assets := []browser.Assetable{
+		bow.Links()[0],
+		bow.Images()[0],
+		bow.Stylesheets()[0],
+		bow.Scripts()[0],
+	}
+	expected := []string{"/page2", "/image.png", "/style.css", "/script.js"}
+	for i, asset := range assets {
+		buff := &bytes.Buffer{}
+		l, err := asset.DownloadWith(bow, buff)
+		ut.AssertNil(err)
+		ut.AssertEquals(expected[i], buff.String())
+		ut.AssertEquals(int(l), buff.Len())
+	}
+
+	u, _ := url.Parse("image.png")
+	buff := &bytes.Buffer{}
+	_, err = browser.NewImageAsset(u, "", "", "").DownloadWith(bow, buff)
+	ut.AssertNil(err)
+	ut.AssertEquals("/image.png", buff.String())
+}
+
+func TestFavicon(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page1" {
+			fmt.Fprint(w, htmlPage1)
+		} else if r.URL.Path == "/dir/page2" {
+			fmt.Fprint(w, htmlPage2)
+		} else {
+			fmt.Fprint(w, htmlFavicon)
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	_, err := bow.Favicon()
+	ut.AssertNotNil(err)
+
+	err = bow.Open(ts.URL + "/page1")
+	ut.AssertNil(err)
+	u, err := bow.Favicon()
+	ut.AssertNil(err)
+	ut.AssertEquals(ts.URL+"/favicon.ico", u.String())
+
+	err = bow.Open(ts.URL + "/dir/page3")
+	ut.AssertNil(err)
+	u, err = bow.Favicon()
+	ut.AssertNil(err)
+	ut.AssertEquals(ts.URL+"/dir/static/icon.png", u.String())
+
+	err = bow.Open(ts.URL + "/dir/page2")
+	ut.AssertNil(err)
+	u, err = bow.Favicon()
+	ut.AssertNil(err)
+	ut.AssertEquals(ts.URL+"/favicon.ico", u.String())
+}
+
+func TestCanonicalURL(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page1" {
+			fmt.Fprint(w, htmlPage1)
+		} else {
+			fmt.Fprint(w, htmlMeta)
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	_, err := bow.CanonicalURL()
+	ut.AssertNotNil(err)
+
+	err = bow.Open(ts.URL + "/articles/surf?utm_source=feed")
+	ut.AssertNil(err)
+	u, err := bow.CanonicalURL()
+	ut.AssertNil(err)
+	ut.AssertEquals(ts.URL+"/articles/surf", u.String())
+
+	err = bow.Open(ts.URL + "/page1")
+	ut.AssertNil(err)
+	_, err = bow.CanonicalURL()
+	ut.AssertNotNil(err)
+	_, ok := err.(errors.ElementNotFound)
+	ut.AssertTrue(ok)
+}
+
+func TestMeta(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, htmlMeta)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertEquals("", bow.Meta("description"))
+
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("A page with meta tags.", bow.Meta("description"))
+	ut.AssertEquals("A page with meta tags.", bow.Meta("Description"))
+	ut.AssertEquals("Surf Meta", bow.MetaProperty("og:title"))
+	ut.AssertEquals("", bow.Meta("keywords"))
+	ut.AssertEquals("", bow.MetaProperty("og:image"))
+	ut.AssertEquals("", bow.Meta("og:title"))
+}
+
+func TestPostRedirect(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/307":
+			http.Redirect(w, r, "/echo", http.StatusTemporaryRedirect)
+		case "/308":
+			http.Redirect(w, r, "/echo", http.StatusPermanentRedirect)
+		case "/echo":
+			b, _ := ioutil.ReadAll(r.Body)
+			fmt.Fprint(w, r.Method+" "+string(b))
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Post(ts.URL+"/307", "text/plain", ioutil.NopCloser(bytes.NewBufferString("surf=307")))
+	ut.AssertNil(err)
+	ut.AssertEquals("POST surf=307", bow.Body())
+
+	err = bow.Post(ts.URL+"/308", "text/plain", io.MultiReader(bytes.NewBufferString("surf=308")))
+	ut.AssertNil(err)
+	ut.AssertEquals("POST surf=308", bow.Body())
+
+	err = bow.PostForm(ts.URL+"/307", url.Values{"surf": {"form"}})
+	ut.AssertNil(err)
+	ut.AssertEquals("POST surf=form", bow.Body())
+
+	bow.SetAttribute(browser.FollowRedirects, false)
+	err = bow.Post(ts.URL+"/307", "text/plain", bytes.NewBufferString("surf=307"))
+	ut.AssertNotNil(err)
+}
+
+func TestExists(t *testing.T) {
+	ut.Run(t)
+	var methods []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		if r.URL.Path != "/page" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ok, err := bow.Exists(ts.URL + "/page")
+	ut.AssertNil(err)
+	ut.AssertTrue(ok)
+	ut.AssertNil(bow.Url())
+
+	ok, err = bow.Exists(ts.URL + "/missing")
+	ut.AssertNil(err)
+	ut.AssertFalse(ok)
+	ut.AssertEquals([]string{"HEAD", "HEAD"}, methods)
+
+	err = bow.Open(ts.URL + "/page")
+	ut.AssertNil(err)
+	ok, err = bow.Exists("/missing")
+	ut.AssertNil(err)
+	ut.AssertFalse(ok)
+	ut.AssertEquals("Surf Page 1", bow.Title())
+	ut.AssertEquals(ts.URL+"/page", bow.Url().String())
+
+	ts.Close()
+	ok, err = bow.Exists(ts.URL + "/page")
+	ut.AssertNotNil(err)
+	ut.AssertFalse(ok)
+}
+
+func TestState(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page2" {
+			w.Header().Set("X-Surf", "page2")
+			fmt.Fprint(w, htmlPage2)
+			return
+		}
+		w.Header().Set("X-Surf", "page1")
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.State())
+	err := bow.Open(ts.URL + "/page1")
+	ut.AssertNil(err)
+	state := bow.State()
+	ut.AssertNotNil(state)
+
+	err = bow.Open(ts.URL + "/page2")
+	ut.AssertNil(err)
+	ut.AssertEquals("Surf Page 2", bow.Title())
+
+	bow.SetState(state)
+	ut.AssertEquals(ts.URL+"/page1", bow.Url().String())
+	ut.AssertEquals("Surf Page 1", bow.Title())
+	ut.AssertEquals(http.StatusOK, bow.StatusCode())
+	ut.AssertEquals("page1", bow.ResponseHeaders().Get("X-Surf"))
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/large" {
+			for i := 0; i < 100; i++ {
+				fmt.Fprint(w, strings.Repeat("surf", 256))
+				w.(http.Flusher).Flush()
+			}
+			return
+		}
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetMaxResponseSize(4096)
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("Surf Page 1", bow.Title())
+
+	err = bow.Open(ts.URL + "/large")
+	ut.AssertNotNil(err)
+	ut.AssertContains("maximum size of 4096 bytes", err.Error())
+	ut.AssertEquals(ts.URL, bow.Url().String())
+
+	bow.SetMaxResponseSize(0)
+	err = bow.Open(ts.URL + "/large")
+	ut.AssertNil(err)
+	ut.AssertEquals(102400, len(bow.Body()))
+}
+
+func TestProtocol(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertEquals("", bow.Protocol())
+	bow.SetTransport(ts.Client().Transport)
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("HTTP/2.0", bow.Protocol())
+	ut.AssertEquals("Surf Page 1", bow.Title())
+
+	bow.ForceHTTP1(true)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("HTTP/1.1", bow.Protocol())
+
+	bow.ForceHTTP1(false)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("HTTP/2.0", bow.Protocol())
+}
+
+func TestClientCertificate(t *testing.T) {
+	ut.Run(t)
+	cert, pool := newClientCertificate("surf-client")
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	ts.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  pool,
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetTransport(ts.Client().Transport)
+	err := bow.Open(ts.URL)
+	ut.AssertNotNil(err)
+
+	bow.SetClientCertificate(cert)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("surf-client", bow.Find("body").Text())
+
+	bow.ForceHTTP1(true)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("surf-client", bow.Find("body").Text())
+
+	err = bow.LoadClientCertificate("missing.pem", "missing.key")
+	ut.AssertNotNil(err)
+}
+
+// newClientCertificate creates a self-signed client certificate with the given
+// common name, and a pool containing the certificate.
+func newClientCertificate(name string) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ut.AssertNil(err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	ut.AssertNil(err)
+	leaf, err := x509.ParseCertificate(der)
+	ut.AssertNil(err)
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+func TestPostMultipartFiles(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/upload", http.StatusTemporaryRedirect)
+			return
+		}
+		fin, header, err := r.FormFile("image")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer fin.Close()
+		b, _ := ioutil.ReadAll(fin)
+		fmt.Fprintf(w, "%s %s %s", r.FormValue("name"), header.Filename, b)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "surf")
+	ut.AssertNil(err)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "surf.txt")
+	err = ioutil.WriteFile(name, []byte("Hello, Surf!"), 0644)
+	ut.AssertNil(err)
+
+	bow := NewBrowser()
+	fields := url.Values{"name": {"surf"}}
+	files := map[string]string{"image": name}
+	err = bow.PostMultipartFiles(ts.URL+"/upload", fields, files)
+	ut.AssertNil(err)
+	ut.AssertEquals(http.StatusOK, bow.StatusCode())
+	ut.AssertEquals("surf surf.txt Hello, Surf!", bow.Body())
+
+	err = bow.PostMultipartFiles(ts.URL+"/redirect", fields, files)
+	ut.AssertNil(err)
+	ut.AssertEquals("surf surf.txt Hello, Surf!", bow.Body())
+
+	files["image"] = filepath.Join(dir, "missing.txt")
+	err = bow.PostMultipartFiles(ts.URL+"/upload", fields, files)
+	ut.AssertNotNil(err)
+}
+
+func TestLanguage(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/attr":
+			w.Header().Set("Content-Language", "fr")
+			fmt.Fprint(w, `<html lang="zh_hant_tw"><head><title>Surf</title></head></html>`)
+		case "/header":
+			w.Header().Set("Content-Language", "en-us, fr")
+			fmt.Fprint(w, htmlPage1)
+		default:
+			fmt.Fprint(w, htmlPage1)
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL + "/attr")
+	ut.AssertNil(err)
+	ut.AssertEquals("zh-Hant-TW", bow.Language())
+
+	err = bow.Open(ts.URL + "/header")
+	ut.AssertNil(err)
+	ut.AssertEquals("en-US", bow.Language())
+
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("", bow.Language())
+}
+
+func TestSetCookieEvent(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "redirect", Value: "surf"})
+			http.Redirect(w, r, "/", http.StatusFound)
+		case "/head":
+			http.SetCookie(w, &http.Cookie{Name: "head", Value: "surf"})
+		default:
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "surf", HttpOnly: true})
+			fmt.Fprint(w, htmlPage1)
+		}
+	}))
+	defer ts.Close()
+
+	var names []string
+	bow := NewBrowser()
+	bow.OnFunc(event.SetCookie, func(_ event.Event, args ...interface{}) error {
+		for _, c := range args[0].([]*http.Cookie) {
+			names = append(names, c.Name)
+		}
+		ut.AssertNotNil(args[1].(*http.Response))
+		return nil
+	})
+	err := bow.Open(ts.URL + "/login")
+	ut.AssertNil(err)
+	ut.AssertEquals([]string{"redirect", "session"}, names)
+
+	_, err = bow.Exists(ts.URL + "/head")
+	ut.AssertNil(err)
+	ut.AssertEquals([]string{"redirect", "session", "head"}, names)
+}
+
+func TestBackForwardEvents(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	var back, forward []string
+	bow := NewBrowser()
+	bow.OnFunc(event.Back, func(_ event.Event, args ...interface{}) error {
+		back = append(back, args[0].(*jar.State).Request.URL.Path)
+		return nil
+	})
+	bow.OnFunc(event.Forward, func(_ event.Event, args ...interface{}) error {
+		forward = append(forward, args[0].(*jar.State).Request.URL.Path)
+		return nil
+	})
+
+	ut.AssertFalse(bow.Back())
+	ut.AssertFalse(bow.Forward())
+	for _, p := range []string{"/1", "/2", "/3"} {
+		err := bow.Open(ts.URL + p)
+		ut.AssertNil(err)
+	}
+
+	ut.AssertTrue(bow.Back())
+	ut.AssertTrue(bow.Back())
+	ut.AssertFalse(bow.Back())
+	ut.AssertEquals("/1", bow.Url().Path)
+	ut.AssertEquals([]string{"/2", "/1"}, back)
+
+	ut.AssertTrue(bow.Forward())
+	ut.AssertEquals("/2", bow.Url().Path)
+	ut.AssertEquals([]string{"/2"}, forward)
+
+	err := bow.Open(ts.URL + "/4")
+	ut.AssertNil(err)
+	ut.AssertFalse(bow.Forward())
+	ut.AssertTrue(bow.Back())
+	ut.AssertEquals("/2", bow.Url().Path)
+	ut.AssertTrue(bow.Forward())
+	ut.AssertEquals("/4", bow.Url().Path)
+	ut.AssertEquals([]string{"/2", "/1", "/2"}, back)
+	ut.AssertEquals([]string{"/2", "/4"}, forward)
+}
+
+func TestPreRequestBody(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprint(w, r.Header.Get("X-Signature")+";"+string(b))
+	}))
+	defer ts.Close()
+
+	var logged []string
+	bow := NewBrowser()
+	bow.OnFunc(event.PreRequest, func(_ event.Event, args ...interface{}) error {
+		req := args[0].(*http.Request)
+		if req.Body == nil {
+			return nil
+		}
+		b, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		logged = append(logged, string(b))
+		req.Header.Set("X-Signature", fmt.Sprintf("%d", len(b)))
+		return nil
+	})
+
+	err := bow.PostForm(ts.URL, url.Values{"user": {"surf"}, "pass": {"secret"}})
+	ut.AssertNil(err)
+	ut.AssertEquals([]string{"pass=secret&user=surf"}, logged)
+	ut.AssertEquals("21;pass=secret&user=surf", bow.Find("body").Text())
+
+	err = bow.Post(ts.URL, "text/plain", ioutil.NopCloser(strings.NewReader("streamed body")))
+	ut.AssertNil(err)
+	ut.AssertEquals("13;streamed body", bow.Find("body").Text())
+}
+
+func TestPreRequestReplace(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.Path+";"+r.Header.Get("X-Signature")+";"+r.Header.Get("X-Trace"))
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.OnFunc(event.PreRequest, func(_ event.Event, args ...interface{}) error {
+		req := args[0].(*http.Request)
+		signed := req.Clone(req.Context())
+		signed.URL.Path = "/signed"
+		signed.Header.Set("X-Signature", "surf")
+		args[0] = signed
+		return nil
+	})
+	bow.OnFunc(event.PreRequest, func(_ event.Event, args ...interface{}) error {
+		args[0].(*http.Request).Header.Set("X-Trace", "1")
+		return nil
+	})
+	var posted *http.Request
+	bow.OnFunc(event.PostRequest, func(_ event.Event, args ...interface{}) error {
+		posted = args[0].(*http.Request)
+		return nil
+	})
+
+	err := bow.Open(ts.URL + "/page")
+	ut.AssertNil(err)
+	ut.AssertEquals("/signed;surf;1", bow.Find("body").Text())
+	ut.AssertEquals("/signed", posted.URL.Path)
+	ut.AssertEquals("/signed", bow.Url().Path)
+}
+
+func TestPreRequestHelpers(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><body><a href="/link">Link</a></body></html>`)
+		case "/signed":
+			fmt.Fprint(w, r.Header.Get("X-Signature"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	var mu sync.Mutex
+	var sent []string
+	bow.OnFunc(event.PreRequest, func(_ event.Event, args ...interface{}) error {
+		req := args[0].(*http.Request)
+		mu.Lock()
+		sent = append(sent, req.Method+" "+req.URL.Path)
+		mu.Unlock()
+		signed := req.Clone(req.Context())
+		signed.URL.Path = "/signed"
+		signed.Header.Set("X-Signature", "surf")
+		args[0] = signed
+		return nil
+	})
+
+	ok, err := bow.Exists(ts.URL + "/exists")
+	ut.AssertNil(err)
+	ut.AssertTrue(ok)
+
+	body, _, err := bow.Stream(ts.URL + "/stream")
+	ut.AssertNil(err)
+	b, _ := ioutil.ReadAll(body)
+	body.Close()
+	ut.AssertEquals("surf", string(b))
+
+	buf := &bytes.Buffer{}
+	u, _ := url.Parse(ts.URL + "/download")
+	_, err = bow.DownloadUrl(u, buf)
+	ut.AssertNil(err)
+	ut.AssertEquals("surf", buf.String())
+
+	results := bow.CheckLinks()
+	ut.AssertEquals(1, len(results))
+	ut.AssertFalse(results[0].Broken())
+
+	ut.AssertEquals([]string{"HEAD /exists", "GET /stream", "GET /download", "HEAD /link"}, sent)
+}
+
+func TestOpenForm(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.RawQuery)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.OpenForm(ts.URL+"/?a=1&c=3&c=4", url.Values{"b": {"2"}, "c": {"5"}})
+	ut.AssertNil(err)
+	ut.AssertEquals("a=1&b=2&c=5", bow.Find("body").Text())
+
+	err = bow.OpenForm(ts.URL, url.Values{"b": {"2"}})
+	ut.AssertNil(err)
+	ut.AssertEquals("b=2", bow.Find("body").Text())
+}
+
+func TestSetReferer(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<a href="/referer">Referer</a>`)
+			return
+		}
+		fmt.Fprint(w, r.Referer())
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetReferer("https://www.google.com/")
+	err := bow.Open(ts.URL + "/referer")
+	ut.AssertNil(err)
+	ut.AssertEquals("https://www.google.com/", bow.Body())
+
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	err = bow.Click("a")
+	ut.AssertNil(err)
+	ut.AssertEquals("https://www.google.com/", bow.Body())
+
+	bow.SetAttribute(browser.SendReferer, false)
+	err = bow.Open(ts.URL + "/referer")
+	ut.AssertNil(err)
+	ut.AssertEquals("", bow.Body())
+
+	bow.SetAttribute(browser.SendReferer, true)
+	bow.SetReferer("")
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	err = bow.Click("a")
+	ut.AssertNil(err)
+	ut.AssertEquals(ts.URL, bow.Body())
+}
+
+func TestSetHostHeaders(t *testing.T) {
+	ut.Run(t)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("X-Api-Key")+";"+r.Header.Get("Accept-Language"))
+	})
+	tsA := httptest.NewServer(handler)
+	defer tsA.Close()
+	tsB := httptest.NewServer(handler)
+	defer tsB.Close()
+	hostA := strings.TrimPrefix(tsA.URL, "http://")
+
+	bow := NewBrowser()
+	bow.AddRequestHeader("Accept-Language", "en-US")
+	bow.AddRequestHeader("X-Api-Key", "global")
+	bow.SetHostHeaders(hostA, http.Header{
+		"X-Api-Key": []string{"secret"},
+	})
+
+	err := bow.Open(tsA.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("secret;en-US", bow.Find("body").Text())
+
+	err = bow.Open(tsB.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("global;en-US", bow.Find("body").Text())
+
+	bow.SetHostHeaders(hostA, nil)
+	err = bow.Open(tsA.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("global;en-US", bow.Find("body").Text())
+}
+
+func TestSetBasicAuthForHost(t *testing.T) {
+	ut.Run(t)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if to := r.URL.Query().Get("to"); to != "" {
+			http.Redirect(w, r, to, http.StatusFound)
+			return
+		}
+		user, pass, ok := r.BasicAuth()
+		fmt.Fprintf(w, "%s:%s:%t;%s", user, pass, ok, r.Header.Get("X-Api-Key"))
+	})
+	tsA := httptest.NewServer(handler)
+	defer tsA.Close()
+	tsB := httptest.NewServer(handler)
+	defer tsB.Close()
+	hostA := strings.TrimPrefix(tsA.URL, "http://")
+
+	bow := NewBrowser()
+	bow.SetHostHeaders(hostA, http.Header{"X-Api-Key": []string{"secret"}})
+	bow.SetBasicAuthForHost(hostA, "surf", "pass")
+
+	err := bow.Open(tsA.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("surf:pass:true;secret", bow.Find("body").Text())
+
+	err = bow.Open(tsB.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("::false;", bow.Find("body").Text())
+
+	err = bow.Open(tsA.URL + "/?to=" + url.QueryEscape(tsB.URL))
+	ut.AssertNil(err)
+	ut.AssertEquals("::false;", bow.Find("body").Text())
+
+	bow.SetBasicAuthForHost(hostA, "", "")
+	err = bow.Open(tsA.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("::false;secret", bow.Find("body").Text())
+}
+
+func TestDryRun(t *testing.T) {
+	ut.Run(t)
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "surf"})
+		fmt.Fprint(w, "<html><body>Hello</body></html>")
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.AddRequestHeader("X-Api-Key", "secret")
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals(1, requests)
+	ut.AssertNil(bow.DryRunRequest())
+
+	bow.SetDryRun(true)
+	err = bow.Post(ts.URL+"/login", "application/x-www-form-urlencoded", strings.NewReader("user=surf"))
+	ut.AssertNil(err)
+	ut.AssertEquals(1, requests)
+	ut.AssertEquals(200, bow.StatusCode())
+	ut.AssertEquals("", bow.Find("body").Text())
+
+	req := bow.DryRunRequest()
+	ut.AssertNotNil(req)
+	ut.AssertEquals("POST", req.Method)
+	ut.AssertEquals(ts.URL+"/login", req.URL.String())
+	ut.AssertEquals("secret", req.Header.Get("X-Api-Key"))
+	c, err := req.Cookie("session")
+	ut.AssertNil(err)
+	ut.AssertEquals("surf", c.Value)
+	body, err := ioutil.ReadAll(req.Body)
+	ut.AssertNil(err)
+	ut.AssertEquals("user=surf", string(body))
+
+	bow.SetDryRun(false)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals(2, requests)
+}
+
+func TestDryRunMultipartFiles(t *testing.T) {
+	ut.Run(t)
+	dir, err := ioutil.TempDir("", "surf")
+	ut.AssertNil(err)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "surf.txt")
+	err = ioutil.WriteFile(name, []byte("Hello, Surf!"), 0644)
+	ut.AssertNil(err)
+
+	bow := NewBrowser()
+	bow.SetDryRun(true)
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		err = bow.PostMultipartFiles("http://localhost/upload", url.Values{"name": {"surf"}}, map[string]string{"image": name})
+		ut.AssertNil(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	ut.AssertTrue(runtime.NumGoroutine() < before+5)
+
+	body, err := ioutil.ReadAll(bow.DryRunRequest().Body)
+	ut.AssertNil(err)
+	ut.AssertContains("Hello, Surf!", string(body))
+}
+
+func TestStream(t *testing.T) {
+	ut.Run(t)
+	chunk := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+	release := make(chan bool)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(chunk)
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write(chunk)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	body, resp, err := bow.Stream(ts.URL + "/large")
+	ut.AssertNil(err)
+	ut.AssertEquals(200, resp.StatusCode)
+	ut.AssertNil(bow.Url())
+
+	// The first half is read before the server writes the second half.
+	first := make([]byte, len(chunk))
+	_, err = io.ReadFull(body, first)
+	ut.AssertNil(err)
+	ut.AssertTrue(bytes.Equal(chunk, first))
+	close(release)
+	rest, err := ioutil.ReadAll(body)
+	ut.AssertNil(err)
+	ut.AssertTrue(bytes.Equal(chunk, rest))
+	ut.AssertNil(body.Close())
+
+	body, resp, err = bow.Stream(ts.URL + "/missing")
+	ut.AssertNotNil(err)
+	ut.AssertNil(body)
+	ut.AssertEquals(404, resp.StatusCode)
+}
+
+func TestCookiesForURL(t *testing.T) {
+	ut.Run(t)
+	bow := NewBrowser()
+	cj := jar.NewMemoryCookies()
+	bow.SetCookieJar(cj)
+
+	ua, _ := url.Parse("https://a.example.com/account/")
+	cj.SetCookies(ua, []*http.Cookie{
+		{Name: "session", Value: "a"},
+		{Name: "secure", Value: "yes", Secure: true},
+	})
+	ub, _ := url.Parse("http://b.example.org/")
+	cj.SetCookies(ub, []*http.Cookie{
+		{Name: "session", Value: "b"},
+	})
+
+	cookies := bow.CookiesForURL("https://a.example.com/account/settings")
+	ut.AssertEquals(2, len(cookies))
+	ut.AssertEquals("session=a", cookies[0].String())
+	ut.AssertEquals("secure=yes", cookies[1].String())
+
+	cookies = bow.CookiesForURL("http://a.example.com/account/")
+	ut.AssertEquals(1, len(cookies))
+	ut.AssertEquals(0, len(bow.CookiesForURL("https://a.example.com/")))
+
+	cookies = bow.CookiesForURL("http://b.example.org/")
+	ut.AssertEquals(1, len(cookies))
+	ut.AssertEquals("session=b", cookies[0].String())
+	ut.AssertEquals(0, len(bow.CookiesForURL("http://c.example.net/")))
+}
+
+func TestRedirectHeaders(t *testing.T) {
+	ut.Run(t)
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.UserAgent()+";"+r.Header.Get("Authorization")+";"+r.Header.Get("X-Api-Key"))
+	}
+	tsB := httptest.NewServer(http.HandlerFunc(echo))
+	defer tsB.Close()
+	tsA := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/local":
+			http.Redirect(w, r, "/echo", http.StatusFound)
+		case "/remote":
+			http.Redirect(w, r, tsB.URL+"/echo", http.StatusFound)
+		default:
+			echo(w, r)
+		}
+	}))
+	defer tsA.Close()
+	hostA := strings.TrimPrefix(tsA.URL, "http://")
+
+	bow := NewBrowser()
+	bow.SetUserAgent("Surf/Test")
+	bow.AddRequestHeader("Authorization", "Basic c3VyZjpzZWNyZXQ=")
+	bow.SetHostHeaders(hostA, http.Header{"X-Api-Key": {"secret"}})
+
+	err := bow.Open(tsA.URL + "/local")
+	ut.AssertNil(err)
+	ut.AssertEquals("Surf/Test;Basic c3VyZjpzZWNyZXQ=;secret", bow.Find("body").Text())
+
+	err = bow.Open(tsA.URL + "/remote")
+	ut.AssertNil(err)
+	ut.AssertEquals("Surf/Test;Basic c3VyZjpzZWNyZXQ=;", bow.Find("body").Text())
+
+	bow.SetAttribute(browser.StripCrossHostHeaders, true)
+	err = bow.Open(tsA.URL + "/remote")
+	ut.AssertNil(err)
+	ut.AssertEquals("Surf/Test;;", bow.Find("body").Text())
+
+	err = bow.Open(tsA.URL + "/local")
+	ut.AssertNil(err)
+	ut.AssertEquals("Surf/Test;Basic c3VyZjpzZWNyZXQ=;secret", bow.Find("body").Text())
+}
+
+func TestURLRewriter(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.Redirect(w, r, "/account", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, r.Host+r.URL.Path)
+	}))
+	defer ts.Close()
+	local, _ := url.Parse(ts.URL)
+
+	bow := NewBrowser()
+	bow.SetURLRewriter(func(u *url.URL) *url.URL {
+		if u.Host != "example.com" {
+			return nil
+		}
+		u.Scheme = local.Scheme
+		u.Host = local.Host
+		return u
+	})
+
+	err := bow.Open("http://example.com/page")
+	ut.AssertNil(err)
+	ut.AssertEquals(local.Host+"/page", bow.Find("body").Text())
+	ut.AssertEquals("http://example.com/page", bow.Url().String())
+
+	err = bow.Open("http://example.com/login")
+	ut.AssertNil(err)
+	ut.AssertEquals(local.Host+"/account", bow.Find("body").Text())
+	ut.AssertEquals("http://example.com/account", bow.Url().String())
+
+	bow.SetURLRewriter(nil)
+	err = bow.Open(ts.URL + "/direct")
+	ut.AssertNil(err)
+	ut.AssertEquals(local.Host+"/direct", bow.Find("body").Text())
+}
+
+func TestJSONMethods(t *testing.T) {
+	ut.Run(t)
+	type user struct {
+		Name  string   `json:"name"`
+		Age   int      `json:"age"`
+		Roles []string `json:"roles"`
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var u user
+		if r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad content type", http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		u.Name = r.Method + " " + u.Name
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(u)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	in := user{Name: "surf", Age: 5, Roles: []string{"admin", "user"}}
+	send := map[string]func(string, interface{}) error{
+		"POST":  bow.PostJSON,
+		"PUT":   bow.PutJSON,
+		"PATCH": bow.PatchJSON,
+	}
+	for method, fn := range send {
+		err := fn(ts.URL+"/users/1", in)
+		ut.AssertNil(err)
+		ut.AssertEquals(200, bow.StatusCode())
+		ut.AssertEquals(method, bow.State().Request.Method)
+
+		var out user
+		err = json.Unmarshal([]byte(bow.Find("body").Text()), &out)
+		ut.AssertNil(err)
+		ut.AssertEquals(method+" surf", out.Name)
+		ut.AssertEquals(in.Age, out.Age)
+		ut.AssertEquals(in.Roles, out.Roles)
+	}
+
+	err := bow.PutJSON(ts.URL, make(chan int))
+	ut.AssertNotNil(err)
+}
+
+func TestRequestedURL(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/moved", http.StatusMovedPermanently)
+		case "/moved":
+			http.Redirect(w, r, "/new/", http.StatusFound)
+		default:
+			fmt.Fprint(w, `<a href="page">Page</a>`)
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertNil(bow.RequestedURL())
+	ut.AssertFalse(bow.WasRedirected())
+
+	err := bow.Open(ts.URL + "/old")
+	ut.AssertNil(err)
+	ut.AssertEquals(ts.URL+"/old", bow.RequestedURL().String())
+	ut.AssertEquals(ts.URL+"/new/", bow.Url().String())
+	ut.AssertTrue(bow.WasRedirected())
+
+	err = bow.Click("a")
+	ut.AssertNil(err)
+	ut.AssertEquals(ts.URL+"/new/page", bow.Url().String())
+	ut.AssertEquals(ts.URL+"/new/page", bow.RequestedURL().String())
+	ut.AssertFalse(bow.WasRedirected())
+
+	ut.AssertTrue(bow.Back())
+	ut.AssertEquals(ts.URL+"/old", bow.RequestedURL().String())
+	ut.AssertTrue(bow.WasRedirected())
+
+	err = bow.Reload()
+	ut.AssertNil(err)
+	ut.AssertEquals(ts.URL+"/new/", bow.RequestedURL().String())
+	ut.AssertEquals(ts.URL+"/new/", bow.Url().String())
+	ut.AssertFalse(bow.WasRedirected())
+}
+
+func TestDocumentParser(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetDocumentParser(func(resp *http.Response) (*goquery.Document, error) {
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		html := strings.Replace(string(b), "</body>", `<p id="injected">Injected</p></body>`, 1)
+		return goquery.NewDocumentFromReader(strings.NewReader(html))
+	})
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("Surf Page 1", bow.Title())
+	ut.AssertEquals("Injected", bow.Find("#injected").Text())
+
+	bow.SetDocumentParser(func(resp *http.Response) (*goquery.Document, error) {
+		return nil, fmt.Errorf("parser failed")
+	})
+	err = bow.Open(ts.URL)
+	ut.AssertNotNil(err)
+	ut.AssertContains("parser failed", err.Error())
+
+	bow.SetDocumentParser(nil)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals(0, bow.Find("#injected").Length())
+}
+
+func TestAccept(t *testing.T) {
+	ut.Run(t)
+	var accept []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header["Accept"]
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	tests := []struct {
+		set      func()
+		expected string
+	}{
+		{bow.AcceptHTML, "text/html, application/xhtml+xml, application/xml;q=0.9, */*;q=0.8"},
+		{bow.AcceptJSON, "application/json"},
+		{bow.AcceptXML, "application/xml, text/xml;q=0.9"},
+		{func() { bow.SetAccept("text/csv", "text/plain;q=0.5") }, "text/csv, text/plain;q=0.5"},
+	}
+	for _, test := range tests {
+		test.set()
+		err := bow.Open(ts.URL)
+		ut.AssertNil(err)
+		ut.AssertEquals([]string{test.expected}, accept)
+	}
+
+	bow.SetAccept()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals(0, len(accept))
+}
+
+func TestStripParams(t *testing.T) {
+	ut.Run(t)
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		fmt.Fprint(w, `<a href="/page?id=2&utm_medium=email&gclid=abc">Page</a>`)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL + "/?id=1&utm_source=news")
+	ut.AssertNil(err)
+	ut.AssertEquals("id=1&utm_source=news", queries[0])
+
+	bow.SetStripParams(browser.DefaultStripParams)
+	err = bow.Open(ts.URL + "/?id=1&utm_source=news&utm_campaign=spring&fbclid=xyz")
+	ut.AssertNil(err)
+	ut.AssertEquals("id=1", queries[1])
+	ut.AssertEquals(ts.URL+"/?id=1", bow.Url().String())
+
+	links := bow.Links()
+	ut.AssertEquals(1, len(links))
+	ut.AssertEquals(ts.URL+"/page?id=2", links[0].URL.String())
+	err = bow.Click("a")
+	ut.AssertNil(err)
+	ut.AssertEquals("id=2", queries[2])
+
+	bow.SetStripParams([]string{"id"})
+	err = bow.Open(ts.URL + "/?id=1&utm_source=news")
+	ut.AssertNil(err)
+	ut.AssertEquals("utm_source=news", queries[3])
+}
+
+func TestPollUntil(t *testing.T) {
+	ut.Run(t)
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) >= 2 && r.URL.Path == "/report" {
+			fmt.Fprint(w, `<div id="report">Done</div>`)
+			return
+		}
+		fmt.Fprint(w, `<p>Generating...</p>`)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.PollUntil(ts.URL+"/report", "#report", 10*time.Millisecond, 5*time.Second)
+	ut.AssertNil(err)
+	ut.AssertEquals(int32(2), atomic.LoadInt32(&requests))
+	ut.AssertEquals("Done", bow.Find("#report").Text())
+
+	err = bow.PollUntil(ts.URL+"/never", "#report", 10*time.Millisecond, 100*time.Millisecond)
+	ut.AssertNotNil(err)
+	_, ok := err.(errors.Timeout)
+	ut.AssertTrue(ok)
+}
+
+func TestDefaultContentType(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("Content-Type"))
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Post(ts.URL, "", strings.NewReader("a=1"))
+	ut.AssertNil(err)
+	ut.AssertEquals(browser.DefaultContentType, bow.Find("body").Text())
+
+	bow.SetDefaultContentType("application/json")
+	err = bow.Post(ts.URL, "", strings.NewReader("{}"))
+	ut.AssertNil(err)
+	ut.AssertEquals("application/json", bow.Find("body").Text())
+
+	err = bow.PostContext(context.Background(), ts.URL, "", strings.NewReader("{}"))
+	ut.AssertNil(err)
+	ut.AssertEquals("application/json", bow.Find("body").Text())
+
+	err = bow.Post(ts.URL, "text/plain", strings.NewReader("surf"))
+	ut.AssertNil(err)
+	ut.AssertEquals("text/plain", bow.Find("body").Text())
+}
+
+func TestBaseHref(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs/page.html":
+			fmt.Fprint(w, htmlBaseHref)
+		case "/other/page.html":
+			fmt.Fprint(w, `<a href="next.html">Next</a>`)
+		default:
+			fmt.Fprint(w, r.Method+" "+r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL + "/docs/page.html")
+	ut.AssertNil(err)
+
+	links := bow.Links()
+	ut.AssertEquals(2, len(links))
+	ut.AssertEquals(ts.URL+"/assets/v2/next.html", links[0].URL.String())
+	ut.AssertEquals(ts.URL+"/root.html", links[1].URL.String())
+	images := bow.Images()
+	ut.AssertEquals(1, len(images))
+	ut.AssertEquals(ts.URL+"/assets/v2/logo.png", images[0].URL.String())
+
+	f, err := bow.Form("form")
+	ut.AssertNil(err)
+	ut.AssertEquals(ts.URL+"/assets/v2/search", f.Action())
+
+	err = bow.Click("a")
+	ut.AssertNil(err)
+	ut.AssertEquals("GET /assets/v2/next.html", bow.Find("body").Text())
+
+	err = bow.Open(ts.URL + "/other/page.html")
+	ut.AssertNil(err)
+	ut.AssertEquals(ts.URL+"/other/next.html", bow.Links()[0].URL.String())
+}
+
+func TestOpenDeadline(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		if n < 5 {
+			time.Sleep(50 * time.Millisecond)
+			http.Redirect(w, r, fmt.Sprintf("/%d", n+1), http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.OpenDeadline(ts.URL+"/4", 5*time.Second)
+	ut.AssertNil(err)
+	ut.AssertEquals(ts.URL+"/5", bow.Url().String())
+
+	start := time.Now()
+	err = bow.OpenDeadline(ts.URL+"/1", 120*time.Millisecond)
+	ut.AssertNotNil(err)
+	_, ok := err.(errors.Timeout)
+	ut.AssertTrue(ok)
+	ut.AssertTrue(time.Since(start) < time.Second)
+	ut.AssertEquals(ts.URL+"/5", bow.Url().String())
+}
+
+func TestHistory(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertEquals(0, len(bow.History()))
+	for _, p := range []string{"/1", "/2", "/3"} {
+		err := bow.Open(ts.URL + p)
+		ut.AssertNil(err)
+	}
+	paths := func() []string {
+		var paths []string
+		for _, st := range bow.History() {
+			paths = append(paths, st.Request.URL.Path)
+		}
+		return paths
+	}
+	ut.AssertEquals([]string{"/1", "/2", "/3"}, paths())
+
+	ut.AssertTrue(bow.Back())
+	ut.AssertEquals([]string{"/1", "/2"}, paths())
+	bow.History()[0].Request = nil
+	ut.AssertEquals([]string{"/1", "/2"}, paths())
+
+	bow.ClearHistory()
+	ut.AssertEquals([]string{"/2"}, paths())
+	ut.AssertFalse(bow.Back())
+	ut.AssertFalse(bow.Forward())
+	ut.AssertEquals("/2", bow.Url().Path)
+
+	err := bow.Open(ts.URL + "/4")
+	ut.AssertNil(err)
+	ut.AssertEquals([]string{"/2", "/4"}, paths())
+	ut.AssertTrue(bow.Back())
+	ut.AssertEquals("/2", bow.Url().Path)
+	ut.AssertFalse(bow.Back())
+}
+
+// stackHistory is a jar.History which implements only the required methods.
+type stackHistory struct {
+	states []*jar.State
+}
+
+func (h *stackHistory) Len() int { return len(h.states) }
+
+func (h *stackHistory) Push(p *jar.State) int {
+	h.states = append(h.states, p)
+	return len(h.states)
+}
+
+func (h *stackHistory) Pop() *jar.State {
+	p := h.Top()
+	if len(h.states) > 0 {
+		h.states = h.states[:len(h.states)-1]
+	}
+	return p
+}
+
+func (h *stackHistory) Top() *jar.State {
+	if len(h.states) == 0 {
+		return nil
+	}
+	return h.states[len(h.states)-1]
+}
+
+func TestHistoryJar(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	his := &stackHistory{}
+	bow := NewBrowser()
+	bow.SetHistoryJar(his)
+	for _, p := range []string{"/1", "/2", "/3"} {
+		err := bow.Open(ts.URL + p)
+		ut.AssertNil(err)
+	}
+	ut.AssertEquals(3, his.Len())
+	ut.AssertEquals(1, len(bow.History()))
+	var buff bytes.Buffer
+	ut.AssertNil(bow.ExportHAR(&buff))
+
+	bow.ClearHistory()
+	ut.AssertEquals(0, his.Len())
+	ut.AssertFalse(bow.Back())
+}
+
+func TestReset(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "surf"})
+		fmt.Fprint(w, r.UserAgent()+";"+r.Header.Get("X-Api-Key")+";"+r.Header.Get("Cookie"))
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetUserAgent("Surf/Test")
+	bow.AddRequestHeader("X-Api-Key", "secret")
+	bow.SetReferer("https://www.google.com/")
+	bow.SetAttribute(browser.SendReferer, false)
+	err := bow.Open(ts.URL + "/1")
+	ut.AssertNil(err)
+	err = bow.Open(ts.URL + "/2")
+	ut.AssertNil(err)
+	ut.AssertEquals("Surf/Test;secret;session=surf", bow.Find("body").Text())
+
+	bow.Reset()
+	ut.AssertNil(bow.Url())
+	ut.AssertEquals(0, len(bow.History()))
+	ut.AssertFalse(bow.Back())
+	ut.AssertEquals(0, len(bow.CookiesForURL(ts.URL)))
+	ut.AssertEquals(int64(0), bow.TotalBytesRead())
+
+	err = bow.Open(ts.URL + "/3")
+	ut.AssertNil(err)
+	ut.AssertEquals("Surf/Test;;", bow.Find("body").Text())
+	ut.AssertFalse(bow.Back())
+}
+
+func TestConcurrentReset(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "surf"})
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetAttribute(browser.RandomUserAgent, true)
+	bow.SetUserAgentPool([]string{"Surf/1", "Surf/2"})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- bow.Open(ts.URL)
+		}()
+		go func() {
+			defer wg.Done()
+			bow.Reset()
+			bow.AddRequestHeader("X-Api-Key", "secret")
+			bow.SetUserAgentPool([]string{"Surf/3"})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		ut.AssertNil(err)
+	}
+}
+
+func TestDocumentFilter(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertGreaterThan(0, len(bow.Scripts()))
+
+	titles := []string{}
+	bow.OnFunc(event.PostRequest, func(_ event.Event, args ...interface{}) error {
+		titles = append(titles, bow.Title())
+		return nil
+	})
+	bow.SetDocumentFilter(
+		func(doc *goquery.Document) {
+			doc.Find("script").Remove()
+		},
+		func(doc *goquery.Document) {
+			doc.Find("title").SetText("Filtered")
+		},
+	)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals(0, len(bow.Scripts()))
+	ut.AssertEquals("Filtered", bow.Title())
+	ut.AssertEquals([]string{"Filtered"}, titles)
+
+	bow.SetDocumentFilter()
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertGreaterThan(0, len(bow.Scripts()))
+}
+
+func TestClientHints(t *testing.T) {
+	ut.Run(t)
+	var headers []http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header)
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetUserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("", headers[0].Get("DNT"))
+	ut.AssertEquals("", headers[0].Get("Sec-Fetch-Mode"))
+	ut.AssertEquals("", headers[0].Get("Sec-Ch-Ua"))
+
+	bow.SetAttribute(browser.ClientHints, true)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("1", headers[1].Get("DNT"))
+	ut.AssertEquals("document", headers[1].Get("Sec-Fetch-Dest"))
+	ut.AssertEquals("navigate", headers[1].Get("Sec-Fetch-Mode"))
+	ut.AssertEquals("none", headers[1].Get("Sec-Fetch-Site"))
+	ut.AssertEquals("?1", headers[1].Get("Sec-Fetch-User"))
+	ut.AssertEquals(`"Chromium";v="120", "Google Chrome";v="120", "Not A(Brand";v="99"`, headers[1].Get("Sec-Ch-Ua"))
+	ut.AssertEquals("?0", headers[1].Get("Sec-Ch-Ua-Mobile"))
+	ut.AssertEquals(`"Windows"`, headers[1].Get("Sec-Ch-Ua-Platform"))
+
+	err = bow.Click("a")
+	ut.AssertNil(err)
+	ut.AssertEquals("same-origin", headers[2].Get("Sec-Fetch-Site"))
+
+	ok, err := bow.Exists(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertTrue(ok)
+	ut.AssertEquals("empty", headers[3].Get("Sec-Fetch-Dest"))
+	ut.AssertEquals("cors", headers[3].Get("Sec-Fetch-Mode"))
+	ut.AssertEquals("same-origin", headers[3].Get("Sec-Fetch-Site"))
+	ut.AssertEquals("", headers[3].Get("Sec-Fetch-User"))
+	ut.AssertEquals("?0", headers[3].Get("Sec-Ch-Ua-Mobile"))
+
+	bow.SetUserAgent("Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0")
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("1", headers[4].Get("DNT"))
+	ut.AssertEquals("navigate", headers[4].Get("Sec-Fetch-Mode"))
+	ut.AssertEquals("", headers[4].Get("Sec-Ch-Ua"))
+	ut.AssertEquals("", headers[4].Get("Sec-Ch-Ua-Platform"))
+
+	bow.SetUserAgent("Mozilla/5.0 (iPhone; CPU iPhone OS 18_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/138.0.7204.156 Mobile/15E148 Safari/604.1")
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("navigate", headers[5].Get("Sec-Fetch-Mode"))
+	ut.AssertEquals("", headers[5].Get("Sec-Ch-Ua"))
+	ut.AssertEquals("", headers[5].Get("Sec-Ch-Ua-Mobile"))
+}
+
+func TestClientHintsSameSite(t *testing.T) {
+	ut.Run(t)
+	var sites []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sites = append(sites, r.Host+" "+r.Header.Get("Sec-Fetch-Site"))
+		fmt.Fprint(w, `<a id="api" href="http://api.example.com/">API</a><a id="other" href="http://example.org/">Other</a>`)
+	}))
+	defer ts.Close()
+
+	dialer := &net.Dialer{}
+	bow := NewBrowser()
+	bow.SetTransport(&http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, ts.Listener.Addr().String())
+		},
+	})
+	bow.SetAttribute(browser.ClientHints, true)
+	err := bow.Open("http://www.example.com/")
+	ut.AssertNil(err)
+	err = bow.Click("#api")
+	ut.AssertNil(err)
+	err = bow.Click("#other")
+	ut.AssertNil(err)
+	ut.AssertEquals([]string{
+		"www.example.com none",
+		"api.example.com same-site",
+		"example.org cross-site",
+	}, sites)
+}
+
+func TestConnectionReuse(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	var dials int32
+	dialer := &net.Dialer{}
+	bow := NewBrowser()
+	bow.SetTransport(&http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return dialer.DialContext(ctx, network, addr)
+		},
+		MaxIdleConnsPerHost: 1,
+	})
+	bow.SetMaxIdleConns(10)
+	bow.SetMaxIdleConnsPerHost(4)
+
+	for i := 0; i < 5; i++ {
+		err := bow.Open(ts.URL)
+		ut.AssertNil(err)
+	}
+	ut.AssertEquals(int32(1), atomic.LoadInt32(&dials))
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bow.Clone().Open(ts.URL)
+		}()
+	}
+	wg.Wait()
+	before := atomic.LoadInt32(&dials)
+	wg = sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bow.Clone().Open(ts.URL)
+		}()
+	}
+	wg.Wait()
+	ut.AssertTrue(atomic.LoadInt32(&dials) <= before+1)
+}
+
+func TestInternationalizedHost(t *testing.T) {
+	ut.Run(t)
+	var host, uri string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		uri = r.RequestURI
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+
+	bow := NewBrowser()
+	bow.SetTransport(&http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, ts.Listener.Addr().String())
+		},
+	})
+	err := bow.Open("http://bücher.example:" + port + "/a%2Fb/c%20d?q=%26")
+	ut.AssertNil(err)
+	ut.AssertEquals("xn--bcher-kva.example:"+port, host)
+	ut.AssertEquals("/a%2Fb/c%20d?q=%26", uri)
+	ut.AssertEquals("http://xn--bcher-kva.example:"+port+"/a%2Fb/c%20d?q=%26", bow.Url().String())
+
+	u, err := bow.ResolveStringUrl("http://b%C3%BCcher.example/x%2Fy")
+	ut.AssertNil(err)
+	ut.AssertEquals("http://xn--bcher-kva.example/x%2Fy", u)
+	u, err = bow.ResolveStringUrl("/e%2Ff")
+	ut.AssertNil(err)
+	ut.AssertEquals("http://xn--bcher-kva.example:"+port+"/e%2Ff", u)
+}
+
+func TestSetLoggerWriter(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	buff := &bytes.Buffer{}
+	bow := NewBrowser()
+	bow.SetLoggerWriter(buff, browser.LevelInfo)
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertContains("INFO Request complete method=GET url="+ts.URL, buff.String())
+	ut.AssertContains("status=200", buff.String())
+
+	buff.Reset()
+	bow.SetLoggerWriter(buff, browser.LevelError)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("", buff.String())
+
+	bow.SetDebug(false)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("", buff.String())
+}
+
+func TestNilCookieJar(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "surf"})
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	var cj *jar.MemoryCookies
+	for _, j := range []http.CookieJar{nil, cj} {
+		bow := NewBrowser()
+		bow.SetCookieJar(j)
+		ut.AssertNil(bow.CookieJar())
+		ut.AssertNil(bow.SiteCookies())
+
+		err := bow.Open(ts.URL)
+		ut.AssertNil(err)
+		ut.AssertNil(bow.SiteCookies())
+		ut.AssertNil(bow.CookiesForURL("/"))
+		ut.AssertNil(bow.ExportCookies())
+		ut.AssertNotNil(bow.Clone())
+
+		err = bow.OpenWithOptions(ts.URL, browser.RequestOptions{CookieJar: cj})
+		ut.AssertNil(err)
+	}
+}
+
+func TestDisableCookies(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("session"); err == nil {
+			fmt.Fprint(w, c.Value)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "surf"})
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.DisableCookies()
+	ut.AssertNil(bow.CookieJar())
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("", bow.Body())
+	ut.AssertNil(bow.SiteCookies())
+
+	bow.EnableCookies()
+	ut.AssertNotNil(bow.CookieJar())
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("surf", bow.Body())
+	ut.AssertEquals(1, len(bow.SiteCookies()))
+}
+
+func TestAsCurl(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "surf"})
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertEquals("", bow.AsCurl())
+	bow.AddRequestHeader("X-Surf", "it's surf")
+	bow.AddRequestHeader("Authorization", "Bearer secret")
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	err = bow.PostForm(ts.URL+"/post", url.Values{"name": {"surf"}})
+	ut.AssertNil(err)
+
+	cmd := bow.AsCurl()
+	ut.AssertContains("curl -X 'POST' '"+ts.URL+"/post'", cmd)
+	ut.AssertContains(`-H 'X-Surf: it'\''s surf'`, cmd)
+	ut.AssertContains("-H 'Content-Type: application/x-www-form-urlencoded'", cmd)
+	ut.AssertContains("--data-binary 'name=surf'", cmd)
+	ut.AssertFalse(strings.Contains(cmd, "secret"))
+	ut.AssertFalse(strings.Contains(cmd, "session=surf"))
+
+	bow.SetAttribute(browser.CurlSensitiveHeaders, true)
+	cmd = bow.AsCurl()
+	ut.AssertContains("-H 'Authorization: Bearer secret'", cmd)
+	ut.AssertContains("-b 'session=surf'", cmd)
+}
+
+func TestExportHAR(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page2" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, htmlPage2)
+			return
+		}
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL + "/page1?q=surf")
+	ut.AssertNil(err)
+	err = bow.Open(ts.URL + "/page2")
+	ut.AssertNil(err)
+
+	buff := &bytes.Buffer{}
+	err = bow.ExportHAR(buff)
+	ut.AssertNil(err)
+
+	var har struct {
+		Log struct {
+			Version string
+			Entries []struct {
+				Request struct {
+					Method      string
+					URL         string
+					QueryString []struct{ Name, Value string }
+				}
+				Response struct {
+					Status  int
+					Content struct{ Size int64 }
+				}
+			}
+		}
+	}
+	err = json.Unmarshal(buff.Bytes(), &har)
+	ut.AssertNil(err)
+	ut.AssertEquals("1.2", har.Log.Version)
+	ut.AssertEquals(2, len(har.Log.Entries))
+	ut.AssertEquals("GET", har.Log.Entries[0].Request.Method)
+	ut.AssertEquals(ts.URL+"/page1?q=surf", har.Log.Entries[0].Request.URL)
+	ut.AssertEquals("q", har.Log.Entries[0].Request.QueryString[0].Name)
+	ut.AssertEquals(http.StatusOK, har.Log.Entries[0].Response.Status)
+	ut.AssertEquals(int64(len(htmlPage1)), har.Log.Entries[0].Response.Content.Size)
+	ut.AssertEquals(ts.URL+"/page2", har.Log.Entries[1].Request.URL)
+	ut.AssertEquals(http.StatusNotFound, har.Log.Entries[1].Response.Status)
+}
+
+func TestRedirectPolicy(t *testing.T) {
+	ut.Run(t)
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/same":
+			http.Redirect(w, r, "/page", http.StatusFound)
+		case "/other":
+			u, _ := url.Parse(ts.URL)
+			http.Redirect(w, r, "http://localhost:"+u.Port()+"/page", http.StatusFound)
+		default:
+			fmt.Fprint(w, htmlPage1)
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	err := bow.Open(ts.URL + "/other")
+	ut.AssertNil(err)
+	ut.AssertEquals("Surf Page 1", bow.Title())
+
+	bow.SetRedirectPolicy(browser.SameDomainRedirects)
+	err = bow.Open(ts.URL + "/same")
+	ut.AssertNil(err)
+	ut.AssertEquals("Surf Page 1", bow.Title())
+
+	err = bow.Open(ts.URL + "/other")
+	ut.AssertNotNil(err)
+	ut.AssertContains("another domain", err.Error())
+}
+
+func TestCache(t *testing.T) {
+	ut.Run(t)
+	var conditional []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/no-store" {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		w.Header().Set("ETag", `"surf"`)
+		if r.Header.Get("If-None-Match") == `"surf"` {
+			conditional = append(conditional, r.URL.Path)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(w, htmlPage1)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetCache(jar.NewMemoryCache())
+	ut.AssertFalse(bow.FromCache())
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals("Surf Page 1", bow.Title())
+	ut.AssertFalse(bow.FromCache())
+
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	ut.AssertEquals([]string{"/"}, conditional)
+	ut.AssertEquals(http.StatusOK, bow.StatusCode())
+	ut.AssertEquals("Surf Page 1", bow.Title())
+	ut.AssertTrue(bow.FromCache())
+
+	err = bow.Open(ts.URL + "/no-store")
+	ut.AssertNil(err)
+	err = bow.Open(ts.URL + "/no-store")
+	ut.AssertNil(err)
+	ut.AssertEquals([]string{"/"}, conditional)
+	ut.AssertEquals("Surf Page 1", bow.Title())
+	ut.AssertFalse(bow.FromCache())
+}
+
+func TestReloadNoCache(t *testing.T) {
+	ut.Run(t)
+	var requests, conditional int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("ETag", `"surf"`)
+		if r.Header.Get("If-None-Match") == `"surf"` {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(w, r.Header.Get("Cache-Control"))
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetCache(jar.NewMemoryCache())
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	err = bow.Reload()
+	ut.AssertNil(err)
+	ut.AssertEquals(2, requests)
+	ut.AssertEquals(1, conditional)
+	ut.AssertEquals("", bow.Find("body").Text())
+
+	err = bow.ReloadNoCache()
+	ut.AssertNil(err)
+	ut.AssertEquals(3, requests)
+	ut.AssertEquals(1, conditional)
+	ut.AssertEquals("no-cache", bow.Find("body").Text())
+
+	err = bow.Reload()
+	ut.AssertNil(err)
+	ut.AssertEquals(4, requests)
+	ut.AssertEquals(2, conditional)
+}
+
+func TestBytesRead(t *testing.T) {
+	ut.Run(t)
+	page1 := "<html><body>" + strings.Repeat("a", 1000) + "</body></html>"
+	page2 := "<html><body>" + strings.Repeat("b", 2500) + "</body></html>"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page2" {
+			fmt.Fprint(w, page2)
+			return
+		}
+		fmt.Fprint(w, page1)
 	}))
 	defer ts.Close()
 
 	bow := NewBrowser()
-	bow.AddRequestHeader("X-Testing-1", "Testing-1")
-	bow.AddRequestHeader("X-Testing-2", "Testing-2")
+	ut.AssertEquals(int64(0), bow.BytesRead())
+	ut.AssertEquals(int64(0), bow.TotalBytesRead())
+
 	err := bow.Open(ts.URL)
 	ut.AssertNil(err)
-	ut.AssertContains("Testing-1", bow.Body())
-	ut.AssertContains("Testing-2", bow.Body())
+	ut.AssertEquals(int64(len(page1)), bow.BytesRead())
+	err = bow.Open(ts.URL + "/page2")
+	ut.AssertNil(err)
+	ut.AssertEquals(int64(len(page2)), bow.BytesRead())
+	ut.AssertEquals(int64(len(page1)+len(page2)), bow.TotalBytesRead())
+
+	ut.AssertTrue(bow.Back())
+	ut.AssertEquals(int64(len(page1)), bow.BytesRead())
+	ut.AssertEquals(int64(len(page1)+len(page2)), bow.TotalBytesRead())
 }
 
-func TestBookmarks(t *testing.T) {
+func TestNonHTMLResponse(t *testing.T) {
 	ut.Run(t)
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+	data := `{"title": "<title>Surf</title>", "body": "<body>Hello</body>"}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/data.json" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, data)
+			return
+		}
 		fmt.Fprint(w, htmlPage1)
 	}))
 	defer ts.Close()
 
-	bookmarks := jar.NewMemoryBookmarks()
+	buff := &bytes.Buffer{}
 	bow := NewBrowser()
-	bow.SetBookmarksJar(bookmarks)
+	bow.SetLoggerWriter(buff, browser.LevelDebug)
+	err := bow.Open(ts.URL + "/data.json")
+	ut.AssertNil(err)
+	ut.AssertEquals("", bow.Title())
+	ut.AssertEquals("", bow.Body())
+	ut.AssertEquals(data, string(bow.ResponseBytes()))
+	ut.AssertContains("DEBUG Page is not HTML method=title content_type=application/json", buff.String())
+	ut.AssertContains("DEBUG Page is not HTML method=body content_type=application/json", buff.String())
+	ut.AssertNil(bow.RawBody())
 
-	bookmarks.Save("test1", ts.URL)
-	bow.OpenBookmark("test1")
-	ut.AssertEquals("Surf Page 1", bow.Title())
-	ut.AssertContains("<p>Hello, Surf!</p>", bow.Body())
+	bow.SetAttribute(browser.ParseHTMLOnly, true)
+	err = bow.Open(ts.URL + "/data.json")
+	ut.AssertNil(err)
+	ut.AssertEquals("", bow.Title())
+	ut.AssertEquals("", bow.Body())
+	ut.AssertEquals(data, string(bow.ResponseBytes()))
 
-	err := bow.Bookmark("test2")
+	err = bow.Open(ts.URL)
 	ut.AssertNil(err)
-	bow.OpenBookmark("test2")
 	ut.AssertEquals("Surf Page 1", bow.Title())
+	ut.AssertContains("<title>Surf Page 1</title>", string(bow.ResponseBytes()))
 }
 
-func TestClick(t *testing.T) {
+func TestParseHTMLOnly(t *testing.T) {
 	ut.Run(t)
+	data := bytes.Repeat([]byte{0, 1, 2, 3, '<', 'p', '>'}, 1024*1024)
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/" {
-			fmt.Fprint(w, htmlPage1)
-		} else if r.URL.Path == "/page2" {
-			fmt.Fprint(w, htmlPage1)
+		if r.URL.Path == "/data.bin" {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(data)
+			return
 		}
+		fmt.Fprint(w, htmlPage1)
 	}))
 	defer ts.Close()
 
 	bow := NewBrowser()
+	bow.SetAttribute(browser.ParseHTMLOnly, true)
 	err := bow.Open(ts.URL)
 	ut.AssertNil(err)
+	ut.AssertEquals("Surf Page 1", bow.Title())
+	ut.AssertNil(bow.RawBody())
 
-	err = bow.Click("a:contains('click')")
+	err = bow.Open(ts.URL + "/data.bin")
 	ut.AssertNil(err)
-	ut.AssertContains("<p>Hello, Surf!</p>", bow.Body())
+	ut.AssertEquals(http.StatusOK, bow.StatusCode())
+	ut.AssertTrue(bytes.Equal(data, bow.RawBody()))
+	ut.AssertEquals(0, bow.Dom().Length())
+	ut.AssertEquals(0, bow.Find("p").Length())
+	ut.AssertEquals("", bow.Title())
+
+	buff := &bytes.Buffer{}
+	n, err := bow.Download(buff)
+	ut.AssertNil(err)
+	ut.AssertEquals(int64(len(data)), n)
+
+	_, err = bow.Form("form")
+	_, ok := err.(errors.PageNotParsed)
+	ut.AssertTrue(ok)
+	_, ok = bow.Click("a").(errors.PageNotParsed)
+	ut.AssertTrue(ok)
 }
 
-func TestLinks(t *testing.T) {
+func TestSetDom(t *testing.T) {
 	ut.Run(t)
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
-		fmt.Fprint(w, htmlPage1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><title>Surf</title><script>alert("surf")</script></head><body><p>Hello, Surf!</p></body></html>`)
 	}))
 	defer ts.Close()
 
 	bow := NewBrowser()
-	err := bow.Open(ts.URL)
+	err := bow.SetDom(bow.Dom())
+	ut.AssertNotNil(err)
+	err = bow.Open(ts.URL)
 	ut.AssertNil(err)
 
-	links := bow.Links()
-	ut.AssertEquals(2, len(links))
-	ut.AssertEquals("", links[0].ID)
-	ut.AssertEquals(ts.URL+"/page2", links[0].URL.String())
-	ut.AssertEquals("click", links[0].Text)
-	ut.AssertEquals("page3", links[1].ID)
-	ut.AssertEquals(ts.URL+"/page3", links[1].URL.String())
-	ut.AssertEquals("no clicking", links[1].Text)
+	dom := bow.Dom().Clone()
+	dom.Find("script").Remove()
+	dom.Find("p").SetHtml("Goodbye, Surf!")
+	err = bow.SetDom(dom)
+	ut.AssertNil(err)
+
+	buff := &bytes.Buffer{}
+	_, err = bow.Download(buff)
+	ut.AssertNil(err)
+	ut.AssertFalse(strings.Contains(buff.String(), "<script>"))
+	ut.AssertContains("<p>Goodbye, Surf!</p>", buff.String())
+	ut.AssertEquals(0, bow.Find("script").Length())
+	ut.AssertEquals("Surf", bow.Title())
+	ut.AssertEquals(ts.URL, bow.Url().String())
+	ut.AssertEquals(http.StatusOK, bow.StatusCode())
 }
 
-func TestImages(t *testing.T) {
+func TestOpenAll(t *testing.T) {
 	ut.Run(t)
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+	var active, maxActive int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			m := atomic.LoadInt32(&maxActive)
+			if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
 		fmt.Fprint(w, htmlPage1)
 	}))
 	defer ts.Close()
@@ -163,29 +3168,47 @@ func TestImages(t *testing.T) {
 	bow := NewBrowser()
 	err := bow.Open(ts.URL)
 	ut.AssertNil(err)
+	atomic.StoreInt32(&maxActive, 0)
 
-	images := bow.Images()
-	ut.AssertEquals(2, len(images))
-	ut.AssertEquals("imgur-image", images[0].ID)
-	ut.AssertEquals("http://i.imgur.com/HW4bJtY.jpg", images[0].URL.String())
-	ut.AssertEquals("", images[0].Alt)
-	ut.AssertEquals("It's a...", images[0].Title)
+	var loaded int32
+	bow.OnFunc(event.PostRequest, func(_ event.Event, _ ...interface{}) error {
+		atomic.AddInt32(&loaded, 1)
+		return nil
+	})
 
-	ut.AssertEquals("", images[1].ID)
-	ut.AssertEquals(ts.URL+"/Cxagv.jpg", images[1].URL.String())
-	ut.AssertEquals("A picture", images[1].Alt)
-	ut.AssertEquals("", images[1].Title)
+	urls := []string{ts.URL + "/1", ts.URL + "/2", "http://%zz", ts.URL + "/3", ts.URL + "/4", ts.URL + "/5", ts.URL + "/1"}
+	errs := bow.OpenAll(urls, 2)
+	ut.AssertEquals(len(urls), len(errs))
+	for i, err := range errs {
+		if i == 2 {
+			ut.AssertNotNil(err)
+		} else {
+			ut.AssertNil(err)
+		}
+	}
+	ut.AssertEquals(int32(6), atomic.LoadInt32(&loaded))
+	ut.AssertTrue(atomic.LoadInt32(&maxActive) <= 2)
+	ut.AssertEquals(ts.URL, bow.Url().String())
+}
 
-	buff := &bytes.Buffer{}
-	l, err := images[0].Download(buff)
+func TestText(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, htmlText)
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertEquals("", bow.Text())
+	err := bow.Open(ts.URL)
 	ut.AssertNil(err)
-	ut.AssertGreaterThan(0, buff.Len())
-	ut.AssertEquals(int(l), buff.Len())
+	ut.AssertEquals("Hello, Surf! Surfing the web.", bow.Text())
+	ut.AssertEquals(1, bow.Find("script").Length())
 }
 
-func TestStylesheets(t *testing.T) {
+func TestFindText(t *testing.T) {
 	ut.Run(t)
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprint(w, htmlPage1)
 	}))
 	defer ts.Close()
@@ -193,48 +3216,228 @@ func TestStylesheets(t *testing.T) {
 	bow := NewBrowser()
 	err := bow.Open(ts.URL)
 	ut.AssertNil(err)
+	ut.AssertEquals("Hello, Surf!", bow.FindText("p"))
+	ut.AssertEquals("", bow.FindText("h6"))
 
-	stylesheets := bow.Stylesheets()
-	ut.AssertEquals(2, len(stylesheets))
-	ut.AssertEquals("http://godoc.org/-/site.css", stylesheets[0].URL.String())
-	ut.AssertEquals("all", stylesheets[0].Media)
-	ut.AssertEquals("text/css", stylesheets[0].Type)
+	href, ok := bow.FindAttr("a", "href")
+	ut.AssertTrue(ok)
+	ut.AssertEquals("/page2", href)
+	_, ok = bow.FindAttr("a", "title")
+	ut.AssertFalse(ok)
+	_, ok = bow.FindAttr("h6", "id")
+	ut.AssertFalse(ok)
+}
 
-	ut.AssertEquals(ts.URL+"/print.css", stylesheets[1].URL.String())
-	ut.AssertEquals("print", stylesheets[1].Media)
-	ut.AssertEquals("text/css", stylesheets[1].Type)
+func TestAttributeSetters(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "/", http.StatusFound)
+		case "/refresh":
+			fmt.Fprint(w, `<html><head><meta http-equiv="refresh" content="10"></head></html>`)
+		default:
+			fmt.Fprint(w, "referer="+r.Referer())
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	bow.SetFollowRedirects(false)
+	err := bow.Open(ts.URL + "/redirect")
+	ut.AssertNotNil(err)
+	ut.AssertContains("Redirects are disabled", err.Error())
+	bow.SetFollowRedirects(true)
+	err = bow.Open(ts.URL + "/redirect")
+	ut.AssertNil(err)
+	ut.AssertEquals(http.StatusOK, bow.StatusCode())
+
+	bow.SetSendReferer(false)
+	err = bow.Open(ts.URL + "/page")
+	ut.AssertNil(err)
+	err = bow.Open(ts.URL + "/page")
+	ut.AssertNil(err)
+	ut.AssertEquals("referer=", bow.Body())
+	bow.SetSendReferer(true)
+	bow.SetReferer(ts.URL + "/from")
+	err = bow.Open(ts.URL + "/page")
+	ut.AssertNil(err)
+	ut.AssertEquals("referer="+ts.URL+"/from", bow.Body())
 
 	buff := &bytes.Buffer{}
-	l, err := stylesheets[0].Download(buff)
+	bow.SetLoggerWriter(buff, browser.LevelDebug)
+	bow.SetMetaRefreshHandling(false)
+	err = bow.Open(ts.URL + "/refresh")
 	ut.AssertNil(err)
-	ut.AssertGreaterThan(0, buff.Len())
-	ut.AssertEquals(int(l), buff.Len())
+	ut.AssertFalse(strings.Contains(buff.String(), "Creating meta refresh timer"))
+	bow.SetMetaRefreshHandling(true)
+	err = bow.Open(ts.URL + "/refresh")
+	ut.AssertNil(err)
+	ut.AssertContains("Creating meta refresh timer", buff.String())
+	bow.StopMetaRefresh()
 }
 
-func TestScripts(t *testing.T) {
+func TestCheckLinks(t *testing.T) {
 	ut.Run(t)
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
-		fmt.Fprint(w, htmlPage1)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	var mu sync.Mutex
+	var methods []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, `<html><body>
+				<a href="/ok">OK</a>
+				<a href="/missing">Missing</a>
+				<a href="/ok#top">OK again</a>
+				<a href="mailto:surf@example.com">Mail</a>
+				<a href="%s/down">Down</a>
+			</body></html>`, closed.URL)
+		case "/ok":
+			mu.Lock()
+			methods = append(methods, r.Method)
+			mu.Unlock()
+		default:
+			mu.Lock()
+			methods = append(methods, r.Method)
+			mu.Unlock()
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	bow := NewBrowser()
+	ut.AssertEquals(0, len(bow.CheckLinks()))
+
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+	results := bow.CheckLinks()
+	ut.AssertEquals(3, len(results))
+	ut.AssertEquals(ts.URL+"/ok", results[0].URL.String())
+	ut.AssertEquals(http.StatusOK, results[0].StatusCode)
+	ut.AssertNil(results[0].Err)
+	ut.AssertFalse(results[0].Broken())
+	ut.AssertEquals(ts.URL+"/missing", results[1].URL.String())
+	ut.AssertEquals(http.StatusNotFound, results[1].StatusCode)
+	ut.AssertTrue(results[1].Broken())
+	ut.AssertEquals(closed.URL+"/down", results[2].URL.String())
+	ut.AssertEquals(0, results[2].StatusCode)
+	ut.AssertNotNil(results[2].Err)
+	ut.AssertTrue(results[2].Broken())
+	ut.AssertEquals([]string{"HEAD", "HEAD"}, methods)
+	ut.AssertEquals(ts.URL, bow.Url().String())
+}
+
+func TestEach(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><ul><li>Go</li><li>Python</li><li>Rust</li></ul></body></html>`)
 	}))
 	defer ts.Close()
 
 	bow := NewBrowser()
+	var items []string
+	bow.Each("li", func(_ int, s *goquery.Selection) {
+		items = append(items, s.Text())
+	})
+	ut.AssertEquals(0, len(items))
+
 	err := bow.Open(ts.URL)
 	ut.AssertNil(err)
+	bow.Each("li", func(i int, s *goquery.Selection) {
+		items = append(items, strconv.Itoa(i)+":"+s.Text())
+	})
+	ut.AssertEquals([]string{"0:Go", "1:Python", "2:Rust"}, items)
+}
 
-	scripts := bow.Scripts()
-	ut.AssertEquals(2, len(scripts))
-	ut.AssertEquals("http://godoc.org/-/site.js", scripts[0].URL.String())
-	ut.AssertEquals("text/javascript", scripts[0].Type)
+func TestNextPrev(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page/1":
+			fmt.Fprint(w, `<html><head><title>Page 1</title><link rel="next" href="2"></head></html>`)
+		case "/page/2":
+			fmt.Fprint(w, `<html><head><title>Page 2</title></head><body><a rel="prev" href="1">Prev</a> <a rel="nofollow next" href="/page/3">Next</a></body></html>`)
+		case "/page/3":
+			fmt.Fprint(w, `<html><head><title>Page 3</title><link rel="previous" href="/page/2"></head></html>`)
+		}
+	}))
+	defer ts.Close()
 
-	ut.AssertEquals(ts.URL+"/jquery.min.js", scripts[1].URL.String())
-	ut.AssertEquals("text/javascript", scripts[1].Type)
+	bow := NewBrowser()
+	err := bow.Open(ts.URL + "/page/1")
+	ut.AssertNil(err)
+	err = bow.Next()
+	ut.AssertNil(err)
+	ut.AssertEquals("Page 2", bow.Title())
+	ut.AssertEquals(ts.URL+"/page/2", bow.Url().String())
+	err = bow.Next()
+	ut.AssertNil(err)
+	ut.AssertEquals("Page 3", bow.Title())
 
-	buff := &bytes.Buffer{}
-	l, err := scripts[0].Download(buff)
+	err = bow.Next()
+	_, ok := err.(errors.ElementNotFound)
+	ut.AssertTrue(ok)
+
+	err = bow.Prev()
 	ut.AssertNil(err)
-	ut.AssertGreaterThan(0, buff.Len())
-	ut.AssertEquals(int(l), buff.Len())
+	ut.AssertEquals("Page 2", bow.Title())
+	err = bow.Prev()
+	ut.AssertNil(err)
+	ut.AssertEquals("Page 1", bow.Title())
+}
+
+func TestOpenReader(t *testing.T) {
+	ut.Run(t)
+	bow := NewBrowser()
+	err := bow.OpenReader(strings.NewReader(htmlPage1), "http://example.com/surf/index.html")
+	ut.AssertNil(err)
+	ut.AssertEquals("Surf Page 1", bow.Title())
+	ut.AssertEquals(http.StatusOK, bow.StatusCode())
+	ut.AssertEquals("http://example.com/surf/index.html", bow.Url().String())
+
+	links := bow.Links()
+	ut.AssertTrue(len(links) > 0)
+	ut.AssertEquals("http://example.com/page2", links[0].Url().String())
+
+	err = bow.OpenReader(strings.NewReader(htmlPage2), "http://example.com/page2")
+	ut.AssertNil(err)
+	ut.AssertEquals("Surf Page 2", bow.Title())
+	ut.AssertTrue(bow.Back())
+	ut.AssertEquals("Surf Page 1", bow.Title())
+
+	err = bow.OpenReader(strings.NewReader(htmlPage1), "%zz")
+	ut.AssertNotNil(err)
+}
+
+func TestNotLoaded(t *testing.T) {
+	ut.Run(t)
+	bow := NewBrowser()
+
+	ut.AssertNil(bow.Url())
+	ut.AssertEquals(0, bow.StatusCode())
+	ut.AssertEquals("", bow.Title())
+	ut.AssertEquals("", bow.Body())
+	ut.AssertEquals(0, len(bow.ResponseHeaders()))
+	ut.AssertNil(bow.Response())
+	ut.AssertEquals(0, bow.Dom().Length())
+	ut.AssertEquals(0, bow.Find("p").Length())
+	ut.AssertEquals("", bow.ContentType())
+	ut.AssertNil(bow.SiteCookies())
+
+	u, _ := url.Parse("http://example.com/surf")
+	ut.AssertEquals(u, bow.ResolveUrl(u))
+
+	buff := &bytes.Buffer{}
+	l, err := bow.Download(buff)
+	ut.AssertEquals(int64(0), l)
+	_, ok := err.(errors.PageNotLoaded)
+	ut.AssertTrue(ok)
+
+	_, ok = bow.Reload().(errors.PageNotLoaded)
+	ut.AssertTrue(ok)
+	_, ok = bow.Bookmark("surf").(errors.PageNotLoaded)
+	ut.AssertTrue(ok)
 }
 
 var htmlPage1 = `<!doctype html>
@@ -273,3 +3476,122 @@ var htmlPage2 = `<!doctype html>
 	</body>
 </html>
 `
+
+var htmlImages = `<!doctype html>
+<html>
+	<head>
+		<title>Surf Images</title>
+	</head>
+	<body>
+		<img src="/images/one.png" />
+		<img src="two.jpg" />
+		<img src="/missing.gif" />
+		<img src="data:image/gif;base64,R0lGODlhAQABAAAAACw=" />
+	</body>
+</html>
+`
+
+var htmlAssets = `<!doctype html>
+<html>
+	<head>
+		<title>Surf Assets</title>
+		<link href="style.css" rel="stylesheet" />
+		<script src="/script.js"></script>
+	</head>
+	<body>
+		<a href="page2">click</a>
+		<img src="/image.png" />
+	</body>
+</html>
+`
+
+var htmlFavicon = `<!doctype html>
+<html>
+	<head>
+		<title>Surf Favicon</title>
+		<link href="/print.css" rel="stylesheet" media="print" />
+		<link href="static/icon.png" rel="Shortcut Icon" />
+	</head>
+	<body>
+		<p>Hello, Surf!</p>
+	</body>
+</html>
+`
+
+var htmlRefresh = `<!doctype html>
+<html>
+	<head>
+		<title>Surf Refresh</title>
+		<meta http-equiv="refresh" content="10">
+	</head>
+	<body>
+		<p>Hello, Surf!</p>
+	</body>
+</html>
+`
+
+var htmlText = `<!doctype html>
+<html>
+	<head>
+		<title>Surf Text</title>
+		<style>p { color: red; }</style>
+	</head>
+	<body>
+		<h1>Hello,
+			Surf!</h1>
+		<script>document.write("surf");</script>
+		<p>Surfing   the <b>web</b>.</p>
+		<noscript>Enable JavaScript</noscript>
+	</body>
+</html>
+`
+
+var htmlSavePage = `<!doctype html>
+<html>
+	<head>
+		<title>Surf Save Page</title>
+		<link href="/css/style.css" rel="stylesheet" />
+		<link href="/favicon.ico" rel="icon" />
+		<script src="js/app.js"></script>
+		<script src="http://external.invalid/lib.js"></script>
+	</head>
+	<body>
+		<img src="/a/logo.png" />
+		<img src="/b/logo.png" />
+		<img src="/a/logo.png" />
+		<img src="data:image/gif;base64,R0lGODlhAQABAAAAACw=" />
+	</body>
+</html>
+`
+
+var htmlBaseHref = `<!doctype html>
+<html>
+	<head>
+		<title>Base Href</title>
+		<base href="../assets/v2/">
+	</head>
+	<body>
+		<a href="next.html">Next</a>
+		<a href="/root.html">Root</a>
+		<img src="logo.png" alt="Logo" />
+		<form method="post" action="search">
+			<input type="text" name="q" value="" />
+		</form>
+	</body>
+</html>
+`
+
+var htmlMeta = `<!doctype html>
+<html>
+	<head>
+		<title>Surf Meta</title>
+		<meta charset="utf-8">
+		<meta name="Description" content="A page with meta tags.">
+		<meta property="og:title" content="Surf Meta">
+		<link rel="canonical" href="surf">
+	</head>
+	<body>
+		<p>Hello, Surf!</p>
+	</body>
+</html>
+`
@@ -0,0 +1,31 @@
+// Package xpath adds XPath querying to the browser.
+//
+// The package is separate from the browser package so the XPath library is
+// only needed by programs which use it.
+package xpath
+
+import (
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/htmlquery"
+	"github.com/headzoo/surf/browser"
+	"github.com/headzoo/surf/errors"
+)
+
+// Find returns the dom selections matching the given XPath expression.
+//
+// The expression is evaluated against the current page of the browser, and the
+// matching elements are returned as a selection just like Browsable.Find().
+// Returns an error when the expression is not valid, or when the browser has no
+// HTML document because no page has been loaded or the page was not parsed.
+func Find(bow browser.Browsable, expr string) (*goquery.Selection, error) {
+	dom := bow.Dom()
+	if dom.Length() == 0 {
+		return nil, errors.NewPageNotLoaded(
+			"Cannot evaluate '%s', the page has no document.", expr)
+	}
+	nodes, err := htmlquery.QueryAll(dom.Get(0), expr)
+	if err != nil {
+		return nil, err
+	}
+	return dom.FindNodes(nodes...), nil
+}
@@ -0,0 +1,82 @@
+package xpath
+
+import (
+	"fmt"
+	"github.com/headzoo/surf"
+	"github.com/headzoo/surf/browser"
+	"github.com/headzoo/surf/errors"
+	"github.com/headzoo/ut"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, htmlPage)
+	}))
+	defer ts.Close()
+
+	bow := surf.NewBrowser()
+	err := bow.Open(ts.URL)
+	ut.AssertNil(err)
+
+	sel, err := Find(bow, "//ul[@id='items']/li")
+	ut.AssertNil(err)
+	ut.AssertEquals(3, sel.Length())
+	ut.AssertEquals(bow.Find("ul#items > li").Text(), sel.Text())
+
+	sel, err = Find(bow, "//a[contains(@class, 'next')]")
+	ut.AssertNil(err)
+	ut.AssertEquals(1, sel.Length())
+	ut.AssertEquals(bow.Find("a.next").Text(), sel.Text())
+	href, _ := sel.Attr("href")
+	ut.AssertEquals("/page2", href)
+
+	sel, err = Find(bow, "//table")
+	ut.AssertNil(err)
+	ut.AssertEquals(0, sel.Length())
+
+	_, err = Find(bow, "//li[")
+	ut.AssertNotNil(err)
+}
+
+func TestFindNoDocument(t *testing.T) {
+	ut.Run(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"surf": true}`)
+	}))
+	defer ts.Close()
+
+	bow := surf.NewBrowser()
+	_, err := Find(bow, "//li")
+	ut.AssertNotNil(err)
+	_, ok := err.(errors.PageNotLoaded)
+	ut.AssertTrue(ok)
+
+	bow.SetAttribute(browser.ParseHTMLOnly, true)
+	err = bow.Open(ts.URL)
+	ut.AssertNil(err)
+	_, err = Find(bow, "//li")
+	ut.AssertNotNil(err)
+	_, ok = err.(errors.PageNotLoaded)
+	ut.AssertTrue(ok)
+}
+
+var htmlPage = `<!doctype html>
+<html>
+	<head>
+		<title>Surf XPath</title>
+	</head>
+	<body>
+		<ul id="items">
+			<li>One</li>
+			<li>Two</li>
+			<li>Three</li>
+		</ul>
+		<a href="/page2" class="link next">Next</a>
+	</body>
+</html>
+`